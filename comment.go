@@ -0,0 +1,65 @@
+package goexiv
+
+// #cgo pkg-config: exiv2
+// #include "helper.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"errors"
+	"runtime"
+	"unsafe"
+)
+
+// Comment returns the image comment, e.g. the COM segment of a JPEG file.
+func (i *Image) Comment() string {
+	if i.closed() {
+		return ""
+	}
+	defer runtime.KeepAlive(i)
+
+	cstr := C.exiv2_image_comment(i.img)
+	defer C.free(unsafe.Pointer(cstr))
+
+	return C.GoString(cstr)
+}
+
+// SetComment sets the image comment.
+func (i *Image) SetComment(comment string) error {
+	return i.edit(func(e *Editor) error {
+		return e.SetComment(comment)
+	})
+}
+
+// ClearComment removes the image comment.
+func (i *Image) ClearComment() error {
+	return i.edit(func(e *Editor) error {
+		return e.ClearComment()
+	})
+}
+
+// SetComment sets the image comment. It fails if the format of the image
+// can't store a comment, e.g. TIFF.
+func (e *Editor) SetComment(comment string) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	if comment != "" && !AccessMode(C.exiv2_image_check_mode(e.img.img, mdComment)).CanWrite() {
+		return errors.New("image format doesn't support comments")
+	}
+
+	cComment := C.CString(comment)
+	defer C.free(unsafe.Pointer(cComment))
+
+	C.exiv2_editor_set_comment(e.ed, cComment)
+
+	return nil
+}
+
+// ClearComment removes the image comment. Unlike SetComment, it succeeds on
+// formats without comments.
+func (e *Editor) ClearComment() error {
+	return e.SetComment("")
+}
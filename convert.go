@@ -0,0 +1,139 @@
+package goexiv
+
+// #cgo pkg-config: exiv2
+// #include "helper.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+// SyncOptions are the options of the Sync methods, which convert metadata
+// from one family to another with Exiv2's converters.
+type SyncOptions struct {
+	// Overwrite replaces the values that already exist in the target
+	// family. Otherwise only missing keys are added.
+	Overwrite bool
+	// Charset is the character set of IPTC data without an
+	// Iptc.Envelope.CharacterSet dataset, e.g. "ISO-8859-1". It is only
+	// used when converting from IPTC, and defaults to ISO-8859-1.
+	Charset string
+}
+
+// SyncExifToXmp converts the EXIF data of the image to XMP.
+func (i *Image) SyncExifToXmp(opts SyncOptions) error {
+	return i.edit(func(e *Editor) error {
+		return e.SyncExifToXmp(opts)
+	})
+}
+
+// SyncIptcToXmp converts the IPTC data of the image to XMP.
+func (i *Image) SyncIptcToXmp(opts SyncOptions) error {
+	return i.edit(func(e *Editor) error {
+		return e.SyncIptcToXmp(opts)
+	})
+}
+
+// SyncXmpToExif converts the XMP data of the image to EXIF.
+func (i *Image) SyncXmpToExif(opts SyncOptions) error {
+	return i.edit(func(e *Editor) error {
+		return e.SyncXmpToExif(opts)
+	})
+}
+
+// SyncXmpToIptc converts the XMP data of the image to IPTC. The converted
+// datasets are UTF-8 strings.
+func (i *Image) SyncXmpToIptc(opts SyncOptions) error {
+	return i.edit(func(e *Editor) error {
+		return e.SyncXmpToIptc(opts)
+	})
+}
+
+// SyncExifToXmp converts the EXIF data to XMP.
+func (e *Editor) SyncExifToXmp(opts SyncOptions) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_editor_sync_exif_to_xmp(e.ed, cBool(opts.Overwrite), &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
+
+// SyncIptcToXmp converts the IPTC data to XMP.
+func (e *Editor) SyncIptcToXmp(opts SyncOptions) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	var cCharset *C.char
+	if opts.Charset != "" {
+		cCharset = C.CString(opts.Charset)
+		defer C.free(unsafe.Pointer(cCharset))
+	}
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_editor_sync_iptc_to_xmp(e.ed, cCharset, cBool(opts.Overwrite), &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
+
+// SyncXmpToExif converts the XMP data to EXIF.
+func (e *Editor) SyncXmpToExif(opts SyncOptions) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_editor_sync_xmp_to_exif(e.ed, cBool(opts.Overwrite), &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
+
+// SyncXmpToIptc converts the XMP data to IPTC. The converted datasets are
+// UTF-8 strings.
+func (e *Editor) SyncXmpToIptc(opts SyncOptions) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_editor_sync_xmp_to_iptc(e.ed, cBool(opts.Overwrite), &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
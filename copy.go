@@ -0,0 +1,115 @@
+package goexiv
+
+// #cgo pkg-config: exiv2
+// #include "helper.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"errors"
+	"runtime"
+	"unsafe"
+)
+
+// CopyOptions are the options of CopyMetadata.
+//
+// Include and Exclude match a key itself and, for XMP, the fields and items
+// of the key. Keys ending with a dot match all the keys starting with them,
+// e.g. "Exif.GPSInfo.".
+type CopyOptions struct {
+	// Formats are the metadata families to copy. All of them are copied if
+	// empty.
+	Formats []MetadataFormat
+	// Include lists the keys to copy. All keys are copied if empty.
+	Include []string
+	// Exclude lists the keys that are not copied.
+	Exclude []string
+	// ICCProfile copies the ICC profile of the source, if it has one.
+	ICCProfile bool
+	// FixDimensions sets the pixel dimension tags of the destination, like
+	// Exif.Photo.PixelXDimension, to its PixelWidth and PixelHeight.
+	FixDimensions bool
+}
+
+// CopyMetadata copies metadata from src to dst. Copied keys replace the
+// ones of dst, other keys of dst are kept.
+func CopyMetadata(dst, src *Image, opts CopyOptions) error {
+	return dst.edit(func(e *Editor) error {
+		return e.CopyMetadata(src, opts)
+	})
+}
+
+// CopyMetadata copies metadata from src. Copied keys replace the existing
+// ones, other keys are kept.
+func (e *Editor) CopyMetadata(src *Image, opts CopyOptions) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	if src.closed() {
+		return ErrImageClosed
+	}
+	defer runtime.KeepAlive(e)
+	defer runtime.KeepAlive(src)
+
+	formats := opts.Formats
+	if len(formats) == 0 {
+		formats = []MetadataFormat{EXIF, IPTC, XMP}
+	}
+
+	cInclude := getCTags(opts.Include)
+	cExclude := getCTags(opts.Exclude)
+	defer func() {
+		for _, cstr := range append(cInclude, cExclude...) {
+			C.free(unsafe.Pointer(cstr))
+		}
+	}()
+
+	var cIncludePtr, cExcludePtr **C.char
+	if len(cInclude) > 0 {
+		cIncludePtr = &cInclude[0]
+	}
+	if len(cExclude) > 0 {
+		cExcludePtr = &cExclude[0]
+	}
+
+	for _, f := range formats {
+		var cerr *C.Exiv2Error
+
+		switch f {
+		case EXIF:
+			C.exiv2_editor_copy_exif(e.ed, src.img, cIncludePtr, C.int(len(cInclude)), cExcludePtr, C.int(len(cExclude)), &cerr)
+		case IPTC:
+			C.exiv2_editor_copy_iptc(e.ed, src.img, cIncludePtr, C.int(len(cInclude)), cExcludePtr, C.int(len(cExclude)), &cerr)
+		case XMP:
+			C.exiv2_editor_copy_xmp(e.ed, src.img, cIncludePtr, C.int(len(cInclude)), cExcludePtr, C.int(len(cExclude)), &cerr)
+		default:
+			return errors.New("invalid metadata format")
+		}
+
+		if cerr != nil {
+			err := makeError(cerr)
+			C.exiv2_error_free(cerr)
+			return err
+		}
+	}
+
+	if opts.ICCProfile {
+		if profile := src.ICCProfile(); profile != nil {
+			e.setICCProfile(profile)
+		}
+	}
+
+	if opts.FixDimensions {
+		var cerr *C.Exiv2Error
+
+		C.exiv2_editor_fix_dimensions(e.ed, &cerr)
+
+		if cerr != nil {
+			err := makeError(cerr)
+			C.exiv2_error_free(cerr)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,601 @@
+package goexiv
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CaptureTimeSource identifies the metadata a capture time was read from.
+type CaptureTimeSource int
+
+const (
+	// CaptureTimeNone means the image has no capture time.
+	CaptureTimeNone CaptureTimeSource = iota
+	// CaptureTimeExif is Exif.Photo.DateTimeOriginal, with
+	// SubSecTimeOriginal and OffsetTimeOriginal.
+	CaptureTimeExif
+	// CaptureTimeXmpPhotoshop is Xmp.photoshop.DateCreated.
+	CaptureTimeXmpPhotoshop
+	// CaptureTimeXmpExif is Xmp.exif.DateTimeOriginal.
+	CaptureTimeXmpExif
+	// CaptureTimeIptc is Iptc.Application2.DateCreated with TimeCreated.
+	CaptureTimeIptc
+)
+
+var captureTimeSourceNames = [...]string{"none", "exif", "xmp-photoshop", "xmp-exif", "iptc"}
+
+func (s CaptureTimeSource) String() string {
+	if s < 0 || int(s) >= len(captureTimeSourceNames) {
+		return fmt.Sprintf("CaptureTimeSource(%d)", int(s))
+	}
+	return captureTimeSourceNames[s]
+}
+
+// captureTime is a capture time read from one source. zoned is false if
+// the source has no UTC offset.
+type captureTime struct {
+	t      time.Time
+	zoned  bool
+	source CaptureTimeSource
+}
+
+// CaptureTime returns the time the image was taken. The sources are tried
+// in order:
+//
+//  1. Exif.Photo.DateTimeOriginal, SubSecTimeOriginal and OffsetTimeOriginal
+//  2. Xmp.photoshop.DateCreated
+//  3. Xmp.exif.DateTimeOriginal
+//  4. Iptc.Application2.DateCreated and TimeCreated
+//
+// Missing or malformed values are skipped. If the chosen source has no UTC
+// offset, the offset of a later source recording the same local time is
+// used. Failing that, the time is returned in UTC, like time.Parse does.
+// An error is only returned if a capture time is present but none can be
+// parsed.
+func (i *Image) CaptureTime() (time.Time, CaptureTimeSource, error) {
+	if i.closed() {
+		return time.Time{}, CaptureTimeNone, ErrImageClosed
+	}
+
+	exifData := i.GetExifData()
+	defer exifData.Close()
+	xmpData := i.GetXmpData()
+	defer xmpData.Close()
+	iptcData := i.GetIptcData()
+	defer iptcData.Close()
+
+	var found []captureTime
+	var firstErr error
+	add := func(c *captureTime, err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err == nil && c != nil {
+			found = append(found, *c)
+		}
+	}
+
+	add(exifCaptureTime(exifData))
+	add(xmpCaptureTime(xmpData, "Xmp.photoshop.DateCreated", CaptureTimeXmpPhotoshop))
+	add(xmpCaptureTime(xmpData, "Xmp.exif.DateTimeOriginal", CaptureTimeXmpExif))
+	add(iptcCaptureTime(iptcData))
+
+	if len(found) == 0 {
+		return time.Time{}, CaptureTimeNone, firstErr
+	}
+
+	best := found[0]
+	if !best.zoned {
+		for _, c := range found[1:] {
+			if c.zoned && sameLocalTime(best.t, c.t) {
+				best.t = time.Date(best.t.Year(), best.t.Month(), best.t.Day(), best.t.Hour(),
+					best.t.Minute(), best.t.Second(), best.t.Nanosecond(), c.t.Location())
+				break
+			}
+		}
+	}
+
+	return best.t, best.source, nil
+}
+
+// SetCaptureTime sets the time the image was taken, in all the sources read
+// by CaptureTime. The UTC offset of t is recorded too.
+func (i *Image) SetCaptureTime(t time.Time) error {
+	return i.edit(func(e *Editor) error {
+		return e.SetCaptureTime(t)
+	})
+}
+
+// SetCaptureTime sets the time the image was taken, in all the sources read
+// by CaptureTime. The UTC offset of t is recorded too.
+func (e *Editor) SetCaptureTime(t time.Time) error {
+	if t.Year() < 1 || t.Year() > 9999 {
+		return fmt.Errorf("capture time %s out of range", t)
+	}
+
+	exif := [][2]string{
+		{exifOriginalTime.key, t.Format(exifDateTimeLayout)},
+		{exifOriginalTime.offsetKey, t.Format("-07:00")},
+	}
+	for _, entry := range exif {
+		if err := e.SetExifString(entry[0], entry[1]); err != nil {
+			return err
+		}
+	}
+
+	if t.Nanosecond() == 0 {
+		if err := e.ExifStripKey(exifOriginalTime.subsecKey); err != nil {
+			return err
+		}
+	} else if err := e.SetExifString(exifOriginalTime.subsecKey, formatSubSec(t.Nanosecond(), 0)); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"Xmp.photoshop.DateCreated", "Xmp.exif.DateTimeOriginal"} {
+		if err := e.SetXmpString(key, t.Format(time.RFC3339Nano)); err != nil {
+			return err
+		}
+	}
+
+	return iptcCreateTime.write(e, t)
+}
+
+// ShiftOptions configures ShiftTimes.
+type ShiftOptions struct {
+	// UpdateOffsets shifts the UTC offsets by the same duration, so that
+	// only the local times change and the GPS times, which are in UTC, are
+	// left alone. Use it when the camera clock was set to the wrong time
+	// zone. The duration must then be a whole number of minutes.
+	UpdateOffsets bool
+}
+
+// xmpShiftedDates are the XMP Date properties shifted by ShiftTimes, besides
+// Xmp.exif.GPSTimeStamp.
+var xmpShiftedDates = []string{
+	"Xmp.xmp.CreateDate",
+	"Xmp.xmp.ModifyDate",
+	"Xmp.photoshop.DateCreated",
+	"Xmp.exif.DateTimeOriginal",
+	"Xmp.exif.DateTimeDigitized",
+	"Xmp.tiff.DateTime",
+}
+
+// ShiftTimes adds d to the date and time fields of the image, in one edit:
+//
+//   - Exif.Image.DateTime, Exif.Photo.DateTimeOriginal and DateTimeDigitized,
+//     with their SubSecTime* and OffsetTime* tags
+//   - Exif.GPSInfo.GPSDateStamp and GPSTimeStamp
+//   - Iptc.Application2.DateCreated, TimeCreated, DigitalCreationDate and
+//     DigitalCreationTime
+//   - Xmp.xmp.CreateDate and ModifyDate, Xmp.photoshop.DateCreated,
+//     Xmp.exif.DateTimeOriginal, DateTimeDigitized and GPSTimeStamp and
+//     Xmp.tiff.DateTime
+//
+// Fields that are malformed, have no time of day or would be out of range
+// once shifted are left unchanged, and their keys are returned.
+func (i *Image) ShiftTimes(d time.Duration, opts ShiftOptions) (skipped []string, err error) {
+	if opts.UpdateOffsets && d%time.Minute != 0 {
+		return nil, fmt.Errorf("UTC offsets can't be shifted by %s", d)
+	}
+	if i.closed() {
+		return nil, ErrImageClosed
+	}
+
+	exifData := i.GetExifData()
+	defer exifData.Close()
+	xmpData := i.GetXmpData()
+	defer xmpData.Close()
+	iptcData := i.GetIptcData()
+	defer iptcData.Close()
+
+	err = i.edit(func(e *Editor) error {
+		s := &timeShifter{e: e, d: d, opts: opts}
+
+		for _, k := range []exifDateTime{exifModifyTime, exifOriginalTime, exifDigitizeTime} {
+			if err := s.shiftExif(exifData, k); err != nil {
+				return err
+			}
+		}
+		if !opts.UpdateOffsets {
+			if err := s.shiftGPS(exifData); err != nil {
+				return err
+			}
+		}
+		for _, k := range []iptcDateTime{iptcCreateTime, iptcDigitalCreateTime} {
+			if err := s.shiftIptc(iptcData, k); err != nil {
+				return err
+			}
+		}
+
+		keys := xmpShiftedDates
+		if !opts.UpdateOffsets {
+			keys = append(keys[:len(keys):len(keys)], "Xmp.exif.GPSTimeStamp")
+		}
+		for _, key := range keys {
+			if err := s.shiftXmp(xmpData, key); err != nil {
+				return err
+			}
+		}
+
+		skipped = s.skipped
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return skipped, nil
+}
+
+// timeShifter shifts the date and time fields of an image through an
+// editor.
+type timeShifter struct {
+	e       *Editor
+	d       time.Duration
+	opts    ShiftOptions
+	skipped []string
+}
+
+// shift returns t shifted by d, with its UTC offset shifted too if zoned is
+// true and the offsets are updated. ok is false if the result is out of
+// range.
+func (s *timeShifter) shift(t time.Time, zoned bool) (shifted time.Time, ok bool) {
+	// time.Parse may return the local time zone, whose offset could
+	// change across the shift
+	_, offset := t.Zone()
+	t = t.In(time.FixedZone("", offset))
+
+	shifted = t.Add(s.d)
+	if zoned && s.opts.UpdateOffsets {
+		offset += int(s.d / time.Second)
+		if offset <= -24*60*60 || offset >= 24*60*60 {
+			return time.Time{}, false
+		}
+		shifted = time.Date(shifted.Year(), shifted.Month(), shifted.Day(), shifted.Hour(), shifted.Minute(),
+			shifted.Second(), shifted.Nanosecond(), time.FixedZone("", offset))
+	}
+
+	return shifted, shifted.Year() >= 1 && shifted.Year() <= 9999
+}
+
+func (s *timeShifter) skip(key string) {
+	s.skipped = append(s.skipped, key)
+}
+
+func (s *timeShifter) shiftExif(d *ExifData, k exifDateTime) error {
+	v, err := k.read(d)
+	if err != nil {
+		s.skip(k.key)
+		return nil
+	}
+	if v == nil {
+		return nil
+	}
+
+	shifted, ok := s.shift(v.t, v.zoned)
+	if !ok {
+		s.skip(k.key)
+		return nil
+	}
+
+	if err := s.e.SetExifString(k.key, shifted.Format(exifDateTimeLayout)); err != nil {
+		return err
+	}
+	if v.subsecDigits > 0 || shifted.Nanosecond() != 0 {
+		if err := s.e.SetExifString(k.subsecKey, formatSubSec(shifted.Nanosecond(), v.subsecDigits)); err != nil {
+			return err
+		}
+	}
+	if s.opts.UpdateOffsets {
+		if v.badOffset {
+			s.skip(k.offsetKey)
+		} else if v.zoned {
+			return s.e.SetExifString(k.offsetKey, shifted.Format("-07:00"))
+		}
+	}
+
+	return nil
+}
+
+func (s *timeShifter) shiftGPS(d *ExifData) error {
+	t, ok := exifGPSTime(d)
+	if ok {
+		t, ok = s.shift(t, false)
+	}
+	if !ok {
+		for _, key := range []string{"Exif.GPSInfo.GPSDateStamp", "Exif.GPSInfo.GPSTimeStamp"} {
+			if datum, _ := d.FindKey(key); datum != nil {
+				s.skip(key)
+			}
+		}
+		return nil
+	}
+
+	clock, date := exifGPSTimeStamp(t)
+	if err := s.e.SetExifValue("Exif.GPSInfo.GPSTimeStamp", clock); err != nil {
+		return err
+	}
+	return s.e.SetExifValue("Exif.GPSInfo.GPSDateStamp", date)
+}
+
+func (s *timeShifter) shiftIptc(d *IptcData, k iptcDateTime) error {
+	t, hasTime, err := k.read(d)
+	if err == nil && t == nil {
+		if clock, _ := iptcString(d, k.timeKey); clock != "" {
+			s.skip(k.timeKey)
+		}
+		return nil
+	}
+	if err != nil || !hasTime {
+		s.skip(k.dateKey)
+		return nil
+	}
+
+	shifted, ok := s.shift(*t, true)
+	if !ok {
+		s.skip(k.dateKey)
+		return nil
+	}
+
+	return k.write(s.e, shifted)
+}
+
+func (s *timeShifter) shiftXmp(d *XmpData, key string) error {
+	value, err := xmpString(d, key)
+	if err != nil || value == "" {
+		return err
+	}
+
+	t, layout, err := parseXmpDate(value)
+	if err != nil || !strings.Contains(layout, "T") {
+		s.skip(key)
+		return nil
+	}
+
+	shifted, ok := s.shift(t, isZonedLayout(layout))
+	if !ok {
+		s.skip(key)
+		return nil
+	}
+
+	return s.e.SetXmpString(key, shifted.Format(strings.Replace(layout, ":05", ":05.999999999", 1)))
+}
+
+const exifDateTimeLayout = "2006:01:02 15:04:05"
+
+// xmpDateLayouts are the forms of an XMP Date, from the most to the least
+// precise. Fractional seconds are accepted after the seconds.
+var xmpDateLayouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// exifDateTime is an EXIF date and time tag with its sub-second and UTC
+// offset tags.
+type exifDateTime struct {
+	key, subsecKey, offsetKey string
+}
+
+var (
+	exifModifyTime   = exifDateTime{"Exif.Image.DateTime", "Exif.Photo.SubSecTime", "Exif.Photo.OffsetTime"}
+	exifOriginalTime = exifDateTime{"Exif.Photo.DateTimeOriginal", "Exif.Photo.SubSecTimeOriginal", "Exif.Photo.OffsetTimeOriginal"}
+	exifDigitizeTime = exifDateTime{"Exif.Photo.DateTimeDigitized", "Exif.Photo.SubSecTimeDigitized", "Exif.Photo.OffsetTimeDigitized"}
+)
+
+// exifTime is a time read from EXIF tags.
+type exifTime struct {
+	t     time.Time
+	zoned bool
+	// subsecDigits is the number of digits of the sub-second tag, 0 if it
+	// is missing.
+	subsecDigits int
+	// badOffset is true if the UTC offset tag is malformed.
+	badOffset bool
+}
+
+// read reads the time from the EXIF tags. It returns nil if the date and
+// time tag is missing or blank. A malformed UTC offset is ignored.
+func (k exifDateTime) read(d *ExifData) (*exifTime, error) {
+	s, err := exifString(d, k.key)
+	if err != nil || isBlankExifDateTime(s) {
+		return nil, err
+	}
+	s = strings.TrimSpace(s)
+
+	offset, err := exifString(d, k.offsetKey)
+	if err != nil {
+		return nil, err
+	}
+	offset = strings.TrimSpace(offset)
+
+	v := &exifTime{}
+	if offset != "" {
+		v.t, err = time.Parse(exifDateTimeLayout+" -07:00", s+" "+offset)
+		v.zoned = err == nil
+		v.badOffset = err != nil
+	}
+	if !v.zoned {
+		if v.t, err = time.Parse(exifDateTimeLayout, s); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", k.key, err)
+		}
+	}
+
+	subsec, err := exifString(d, k.subsecKey)
+	if err != nil {
+		return nil, err
+	}
+	if ns, ok := parseSubSec(subsec); ok {
+		v.t = v.t.Add(time.Duration(ns))
+		v.subsecDigits = len(strings.TrimSpace(subsec))
+	}
+
+	return v, nil
+}
+
+// iptcDateTime is an IPTC date dataset with its time dataset.
+type iptcDateTime struct {
+	dateKey, timeKey string
+}
+
+var (
+	iptcCreateTime        = iptcDateTime{"Iptc.Application2.DateCreated", "Iptc.Application2.TimeCreated"}
+	iptcDigitalCreateTime = iptcDateTime{"Iptc.Application2.DigitalCreationDate", "Iptc.Application2.DigitalCreationTime"}
+)
+
+// read reads the time from the IPTC datasets. It returns nil if the date
+// is missing. hasTime is false if the time is missing, in which case t is
+// midnight UTC.
+func (k iptcDateTime) read(d *IptcData) (t *time.Time, hasTime bool, err error) {
+	date, err := iptcString(d, k.dateKey)
+	if err != nil || date == "" {
+		return nil, false, err
+	}
+	clock, err := iptcString(d, k.timeKey)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var v time.Time
+	if clock == "" {
+		v, err = time.Parse("2006-01-02", date)
+	} else {
+		v, err = time.Parse("2006-01-02T15:04:05-07:00", date+"T"+clock)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid %s: %w", k.dateKey, err)
+	}
+
+	return &v, clock != "", nil
+}
+
+// write sets the IPTC datasets to t, in its UTC offset.
+func (k iptcDateTime) write(e *Editor, t time.Time) error {
+	if err := e.SetIptcValue(k.dateKey, NewDateValue(t)); err != nil {
+		return err
+	}
+	return e.SetIptcValue(k.timeKey, NewTimeValue(t))
+}
+
+// exifCaptureTime reads the capture time from the EXIF tags. It returns nil
+// if DateTimeOriginal is missing or blank.
+func exifCaptureTime(d *ExifData) (*captureTime, error) {
+	v, err := exifOriginalTime.read(d)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	return &captureTime{t: v.t, zoned: v.zoned, source: CaptureTimeExif}, nil
+}
+
+// xmpCaptureTime reads the capture time from an XMP Date property. It
+// returns nil if the property is missing.
+func xmpCaptureTime(d *XmpData, key string, source CaptureTimeSource) (*captureTime, error) {
+	s, err := xmpString(d, key)
+	if err != nil || s == "" {
+		return nil, err
+	}
+
+	t, layout, err := parseXmpDate(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	return &captureTime{t: t, zoned: isZonedLayout(layout), source: source}, nil
+}
+
+// iptcCaptureTime reads the capture time from the IPTC datasets. It returns
+// nil if DateCreated is missing. The time is midnight if TimeCreated is
+// missing.
+func iptcCaptureTime(d *IptcData) (*captureTime, error) {
+	t, hasTime, err := iptcCreateTime.read(d)
+	if err != nil || t == nil {
+		return nil, err
+	}
+
+	return &captureTime{t: *t, zoned: hasTime, source: CaptureTimeIptc}, nil
+}
+
+// parseXmpDate parses an XMP Date, i.e. an ISO 8601 date with an optional
+// time and UTC offset, and returns the matching layout of xmpDateLayouts.
+func parseXmpDate(s string) (t time.Time, layout string, err error) {
+	s = strings.TrimSpace(s)
+	for _, layout = range xmpDateLayouts {
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, layout, nil
+		}
+	}
+	return time.Time{}, "", err
+}
+
+// isZonedLayout reports whether a layout of xmpDateLayouts has a UTC
+// offset.
+func isZonedLayout(layout string) bool {
+	return strings.HasSuffix(layout, "Z07:00")
+}
+
+// parseSubSec returns the nanoseconds of an EXIF SubSecTime value, the
+// decimal digits of a fraction of a second.
+func parseSubSec(s string) (int64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+
+	var ns int64
+	for n := 0; n < 9; n++ {
+		ns *= 10
+		if n >= len(s) {
+			continue
+		}
+		if s[n] < '0' || s[n] > '9' {
+			return 0, false
+		}
+		ns += int64(s[n] - '0')
+	}
+
+	return ns, true
+}
+
+// formatSubSec formats nanoseconds as an EXIF SubSecTime value with the
+// given number of digits, or without trailing zeros if digits is 0.
+func formatSubSec(ns int, digits int) string {
+	s := fmt.Sprintf("%09d", ns)
+	if digits > len(s) {
+		digits = len(s)
+	}
+	if digits > 0 {
+		return s[:digits]
+	}
+	if s = strings.TrimRight(s, "0"); s == "" {
+		return "0"
+	}
+	return s
+}
+
+// isBlankExifDateTime reports whether an EXIF date and time is unknown,
+// which the standard records as blanks or zeros.
+func isBlankExifDateTime(s string) bool {
+	return strings.Trim(s, " :0\x00") == ""
+}
+
+// sameLocalTime reports whether two times have the same wall clock, to the
+// second.
+func sameLocalTime(a, b time.Time) bool {
+	return a.Format(exifDateTimeLayout) == b.Format(exifDateTimeLayout)
+}
+
+// iptcString returns the value of an IPTC dataset as a string, or an empty
+// string if the dataset is missing.
+func iptcString(d *IptcData, key string) (string, error) {
+	s, err := d.GetString(key)
+	if err == ErrMetadataKeyNotFound {
+		return "", nil
+	}
+	return s, err
+}
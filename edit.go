@@ -0,0 +1,601 @@
+package goexiv
+
+// #cgo pkg-config: exiv2
+// #include "helper.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"runtime"
+	"strings"
+	"unsafe"
+)
+
+// ErrEditFinished is returned when an Editor is used after Commit or
+// Rollback has been called.
+var ErrEditFinished = errors.New("edit already committed or rolled back")
+
+// Editor batches metadata changes in memory. Nothing is written to the image
+// until Commit is called, which writes every change at once.
+type Editor struct {
+	img *Image
+	ed  *C.Exiv2Editor
+}
+
+// Begin starts a new batch of metadata changes on the image.
+func (i *Image) Begin() (*Editor, error) {
+	if i.closed() {
+		return nil, ErrImageClosed
+	}
+	defer runtime.KeepAlive(i)
+
+	e := &Editor{
+		img: i,
+		ed:  C.exiv2_image_editor(i.img),
+	}
+
+	runtime.SetFinalizer(e, func(x *Editor) {
+		x.free()
+	})
+
+	return e, nil
+}
+
+// edit runs fn on a new Editor and commits its changes, unless fn fails.
+func (i *Image) edit(fn func(e *Editor) error) error {
+	e, err := i.Begin()
+	if err != nil {
+		return err
+	}
+	defer e.Rollback()
+
+	if err := fn(e); err != nil {
+		return err
+	}
+
+	return e.Commit()
+}
+
+// check returns the error to report if the editor can't be used anymore.
+func (e *Editor) check() error {
+	if e.ed == nil {
+		return ErrEditFinished
+	}
+	if e.img.closed() {
+		return ErrImageClosed
+	}
+	return nil
+}
+
+// free releases the underlying C++ editor.
+func (e *Editor) free() {
+	if e.ed == nil {
+		return
+	}
+
+	C.exiv2_editor_free(e.ed)
+	e.ed = nil
+	runtime.SetFinalizer(e, nil)
+}
+
+// Commit writes all pending changes to the image. If the write fails, none
+// of them is applied and the image keeps its metadata. The Editor can't be
+// used anymore afterwards.
+func (e *Editor) Commit() error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer e.free()
+	defer runtime.KeepAlive(e)
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_editor_commit(e.ed, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
+
+// Rollback discards all pending changes. The Editor can't be used anymore
+// afterwards.
+func (e *Editor) Rollback() error {
+	if e.ed == nil {
+		return ErrEditFinished
+	}
+
+	e.free()
+
+	return nil
+}
+
+// SetMetadataString Sets an exif, iptc or xmp key with a given string value
+func (e *Editor) SetMetadataString(f MetadataFormat, key, value string) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	cKey := C.CString(key)
+	cValue := C.CString(value)
+
+	defer func() {
+		C.free(unsafe.Pointer(cKey))
+		C.free(unsafe.Pointer(cValue))
+	}()
+
+	var cerr *C.Exiv2Error
+
+	switch f {
+	case EXIF:
+		C.exiv2_editor_set_exif_string(e.ed, cKey, cValue, &cerr)
+	case IPTC:
+		C.exiv2_editor_set_iptc_string(e.ed, cKey, cValue, &cerr)
+	case XMP:
+		C.exiv2_editor_set_xmp_string(e.ed, cKey, cValue, &cerr)
+	default:
+		return errors.New("invalid metadata type")
+	}
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
+
+// SetMetadataShort Sets an exif or iptc key with a given short value
+func (e *Editor) SetMetadataShort(f MetadataFormat, key, value string) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	cKey := C.CString(key)
+	cValue := C.CString(value)
+
+	defer func() {
+		C.free(unsafe.Pointer(cKey))
+		C.free(unsafe.Pointer(cValue))
+	}()
+
+	var cerr *C.Exiv2Error
+
+	switch f {
+	case EXIF:
+		C.exiv2_editor_set_exif_short(e.ed, cKey, cValue, &cerr)
+	case IPTC:
+		C.exiv2_editor_set_iptc_short(e.ed, cKey, cValue, &cerr)
+	default:
+		return errors.New("invalid metadata type")
+	}
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
+
+// Set sets a key to a value given as text, using the type Exiv2's tag
+// registry defines for the key. See Image.Set.
+func (e *Editor) Set(key, value string) error {
+	f, err := formatOfKey(key)
+	if err != nil {
+		return err
+	}
+
+	t, err := DefaultTypeID(f, key)
+	if err != nil {
+		return err
+	}
+	if t == TypeUnsignedRational || t == TypeSignedRational {
+		value = rationalText(value)
+	}
+
+	return e.SetValue(f, key, NewValue(t, value))
+}
+
+// rationalText converts the integer and decimal components of a rational
+// value to fractions, e.g. "300 2.8" to "300/1 14/5", as Exiv2 only reads
+// fractions. Decimals are rounded to 1/1000000000. Other components are
+// kept for Exiv2 to reject.
+func rationalText(text string) string {
+	const maxDenominator = 1000000000
+
+	fields := strings.Fields(text)
+	for i, s := range fields {
+		if strings.Contains(s, "/") {
+			continue
+		}
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			continue
+		}
+		if r.Denom().Cmp(big.NewInt(maxDenominator)) > 0 {
+			f, _ := r.Float64()
+			if math.Abs(f) >= math.MaxInt64/maxDenominator {
+				continue
+			}
+			r.SetFrac64(int64(math.Round(f*maxDenominator)), maxDenominator)
+		}
+		fields[i] = r.String()
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// SetValue sets a key of the given metadata format to a typed value
+func (e *Editor) SetValue(f MetadataFormat, key string, v Value) error {
+	if v.err != nil {
+		return v.err
+	}
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	cKey := C.CString(key)
+	cText := C.CString(v.text)
+
+	defer func() {
+		C.free(unsafe.Pointer(cKey))
+		C.free(unsafe.Pointer(cText))
+	}()
+
+	var cData *C.uchar
+	if len(v.data) > 0 {
+		cData = (*C.uchar)(unsafe.Pointer(&v.data[0]))
+	}
+	cSize := C.long(len(v.data))
+	cType := C.int(v.typeID)
+
+	var cerr *C.Exiv2Error
+
+	switch f {
+	case EXIF:
+		C.exiv2_editor_set_exif_value(e.ed, cKey, cType, cText, cData, cSize, &cerr)
+	case IPTC:
+		C.exiv2_editor_set_iptc_value(e.ed, cKey, cType, cText, cData, cSize, &cerr)
+	case XMP:
+		C.exiv2_editor_set_xmp_value(e.ed, cKey, cType, cText, cData, cSize, &cerr)
+	default:
+		return errors.New("invalid metadata type")
+	}
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
+
+// StripKey removes a key from the metadata
+func (e *Editor) StripKey(f MetadataFormat, key string) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	ckey := C.CString(key)
+	defer C.free(unsafe.Pointer(ckey))
+
+	var cErr *C.Exiv2Error
+
+	switch f {
+	case EXIF:
+		C.exiv2_editor_exif_strip_key(e.ed, ckey, &cErr)
+	case IPTC:
+		C.exiv2_editor_iptc_strip_key(e.ed, ckey, &cErr)
+	case XMP:
+		C.exiv2_editor_xmp_strip_key(e.ed, ckey, &cErr)
+	default:
+		return errors.New("invalid metadata format")
+	}
+
+	if cErr != nil {
+		err := makeError(cErr)
+		C.exiv2_error_free(cErr)
+		return err
+	}
+
+	return nil
+}
+
+// AddIptcString adds a new entry to an IPTC dataset, keeping the existing
+// ones. It returns an error if the dataset already has an entry and isn't
+// repeatable.
+func (e *Editor) AddIptcString(key, value string) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	cKey := C.CString(key)
+	cValue := C.CString(value)
+
+	defer func() {
+		C.free(unsafe.Pointer(cKey))
+		C.free(unsafe.Pointer(cValue))
+	}()
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_editor_add_iptc_string(e.ed, cKey, cValue, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
+
+// SetIptcStrings replaces every entry of an IPTC dataset with one entry per
+// value.
+func (e *Editor) SetIptcStrings(key string, values []string) error {
+	if err := e.DeleteIptcAll(key); err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		if err := e.AddIptcString(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteIptcAll removes every entry of an IPTC dataset.
+func (e *Editor) DeleteIptcAll(key string) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	ckey := C.CString(key)
+	defer C.free(unsafe.Pointer(ckey))
+
+	var cErr *C.Exiv2Error
+
+	C.exiv2_editor_iptc_strip_all(e.ed, ckey, &cErr)
+
+	if cErr != nil {
+		err := makeError(cErr)
+		C.exiv2_error_free(cErr)
+		return err
+	}
+
+	return nil
+}
+
+// SetXmpArray sets an XMP key to an array of the given kind, which must be
+// TypeXmpBag, TypeXmpSeq or TypeXmpAlt.
+func (e *Editor) SetXmpArray(key string, kind TypeID, values []string) error {
+	if kind != TypeXmpBag && kind != TypeXmpSeq && kind != TypeXmpAlt {
+		return fmt.Errorf("%s is not an XMP array type", kind)
+	}
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	cKey := C.CString(key)
+	defer C.free(unsafe.Pointer(cKey))
+
+	cValues := getCTags(values)
+	defer func() {
+		for _, cstr := range cValues {
+			C.free(unsafe.Pointer(cstr))
+		}
+	}()
+
+	var cValuesPtr **C.char
+	if len(cValues) > 0 {
+		cValuesPtr = &cValues[0]
+	}
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_editor_set_xmp_array(e.ed, cKey, C.int(kind), cValuesPtr, C.int(len(cValues)), &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
+
+// SetLangAlt sets the text of one language of an XMP language alternative,
+// keeping the other languages. Use "x-default" for the default language.
+func (e *Editor) SetLangAlt(key, lang, value string) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	cKey := C.CString(key)
+	cLang := C.CString(lang)
+	cValue := C.CString(value)
+
+	defer func() {
+		C.free(unsafe.Pointer(cKey))
+		C.free(unsafe.Pointer(cLang))
+		C.free(unsafe.Pointer(cValue))
+	}()
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_editor_set_xmp_lang_alt(e.ed, cKey, cLang, cValue, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
+
+// SetXmpStruct sets the fields of an XMP structure, creating the structure
+// if needed. Field names are qualified with their namespace prefix, e.g.
+// "Iptc4xmpCore:CiEmailWork".
+func (e *Editor) SetXmpStruct(key string, fields map[string]string) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	cKey := C.CString(key)
+	defer C.free(unsafe.Pointer(cKey))
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_editor_set_xmp_struct(e.ed, cKey, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	for field, value := range fields {
+		if err := e.SetXmpString(XmpStructField(key, field), value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetXmpPacket replaces the XMP data with a serialized XMP packet. The
+// packet is written as is, unless the XMP data is changed afterwards.
+func (e *Editor) SetXmpPacket(packet []byte) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	var cPacket *C.char
+	if len(packet) > 0 {
+		cPacket = (*C.char)(unsafe.Pointer(&packet[0]))
+	}
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_editor_set_xmp_packet(e.ed, cPacket, C.long(len(packet)), &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
+
+// stripPrefix removes all the keys of a metadata format starting with
+// prefix.
+func (e *Editor) stripPrefix(f MetadataFormat, prefix string) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	cPrefix := C.CString(prefix)
+	defer C.free(unsafe.Pointer(cPrefix))
+
+	switch f {
+	case EXIF:
+		C.exiv2_editor_exif_strip_prefix(e.ed, cPrefix)
+	case IPTC:
+		C.exiv2_editor_iptc_strip_prefix(e.ed, cPrefix)
+	case XMP:
+		C.exiv2_editor_xmp_strip_prefix(e.ed, cPrefix)
+	default:
+		return errors.New("invalid metadata format")
+	}
+
+	return nil
+}
+
+// setICCProfile replaces the ICC profile of the image. An empty profile
+// clears it.
+func (e *Editor) setICCProfile(profile []byte) {
+	defer runtime.KeepAlive(e)
+
+	var cProfile *C.uchar
+	if len(profile) > 0 {
+		cProfile = (*C.uchar)(unsafe.Pointer(&profile[0]))
+	}
+
+	C.exiv2_editor_set_icc_profile(e.ed, cProfile, C.long(len(profile)))
+}
+
+// SetExifString sets an EXIF key with a given string value.
+func (e *Editor) SetExifString(key, value string) error {
+	return e.SetMetadataString(EXIF, key, value)
+}
+
+// SetIptcString sets an IPTC key with a given string value.
+func (e *Editor) SetIptcString(key, value string) error {
+	return e.SetMetadataString(IPTC, key, value)
+}
+
+// SetIptcShort sets an IPTC key with a given short value.
+func (e *Editor) SetIptcShort(key, value string) error {
+	return e.SetMetadataShort(IPTC, key, value)
+}
+
+// SetXmpString sets an XMP key with a given string value.
+func (e *Editor) SetXmpString(key, value string) error {
+	return e.SetMetadataString(XMP, key, value)
+}
+
+// SetExifValue sets an EXIF key with a given typed value.
+func (e *Editor) SetExifValue(key string, v Value) error {
+	return e.SetValue(EXIF, key, v)
+}
+
+// SetIptcValue sets an IPTC key with a given typed value.
+func (e *Editor) SetIptcValue(key string, v Value) error {
+	return e.SetValue(IPTC, key, v)
+}
+
+// SetXmpValue sets an XMP key with a given typed value.
+func (e *Editor) SetXmpValue(key string, v Value) error {
+	return e.SetValue(XMP, key, v)
+}
+
+// ExifStripKey removes the given key from the EXIF data.
+func (e *Editor) ExifStripKey(key string) error {
+	return e.StripKey(EXIF, key)
+}
+
+// IptcStripKey removes the given key from the IPTC data.
+func (e *Editor) IptcStripKey(key string) error {
+	return e.StripKey(IPTC, key)
+}
+
+// XmpStripKey removes the given key from the XMP data.
+func (e *Editor) XmpStripKey(key string) error {
+	return e.StripKey(XMP, key)
+}
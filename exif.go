@@ -33,20 +33,39 @@ func makeExifData(img *Image, cdata *C.Exiv2ExifData) *ExifData {
 	}
 
 	runtime.SetFinalizer(data, func(x *ExifData) {
-		C.exiv2_exif_data_free(x.data)
+		x.Close()
 	})
 
 	return data
 }
 
+// Close frees the underlying C++ structure. Calling Close more than once is
+// a no-op.
+func (d *ExifData) Close() error {
+	if d.data == nil {
+		return nil
+	}
+
+	C.exiv2_exif_data_free(d.data)
+	d.data = nil
+	runtime.SetFinalizer(d, nil)
+
+	return nil
+}
+
+// closed reports whether the data or the image it belongs to has been closed.
+func (d *ExifData) closed() bool {
+	return d.data == nil || d.img.closed()
+}
+
 func makeExifDatum(data *ExifData, cdatum *C.Exiv2ExifDatum) *ExifDatum {
 	if cdatum == nil {
 		return nil
 	}
 
 	datum := &ExifDatum{
-		data,
-		cdatum,
+		data:  data,
+		datum: cdatum,
 	}
 
 	runtime.SetFinalizer(datum, func(x *ExifDatum) {
@@ -57,6 +76,11 @@ func makeExifDatum(data *ExifData, cdatum *C.Exiv2ExifDatum) *ExifDatum {
 }
 
 func (i *Image) GetExifData() *ExifData {
+	if i.closed() {
+		return &ExifData{img: i}
+	}
+	defer runtime.KeepAlive(i)
+
 	return makeExifData(i, C.exiv2_image_get_exif_data(i.img))
 }
 
@@ -64,6 +88,11 @@ func (i *Image) SetExifString(key, value string) error {
 	return i.SetMetadataString(EXIF, key, value)
 }
 
+// SetExifValue sets an EXIF key with a given typed value.
+func (i *Image) SetExifValue(key string, v Value) error {
+	return i.SetValue(EXIF, key, v)
+}
+
 func (d *ExifData) GetString(key string) (string, error) {
 	datum, err := d.FindKey(key)
 	if err != nil {
@@ -78,6 +107,11 @@ func (d *ExifData) GetString(key string) (string, error) {
 }
 
 func (d *ExifData) FindKey(key string) (*ExifDatum, error) {
+	if d.closed() {
+		return nil, ErrImageClosed
+	}
+	defer runtime.KeepAlive(d)
+
 	ckey := C.CString(key)
 	defer C.free(unsafe.Pointer(ckey))
 
@@ -96,39 +130,180 @@ func (d *ExifData) FindKey(key string) (*ExifDatum, error) {
 
 // Key returns the Exif key of the datum.
 func (d *ExifDatum) Key() string {
-	return C.GoString(C.exiv2_exif_datum_key(d.datum))
+	if d.data.img.closed() {
+		return ""
+	}
+	defer runtime.KeepAlive(d)
+
+	cstr := C.exiv2_exif_datum_key(d.datum)
+	defer C.free(unsafe.Pointer(cstr))
+
+	return C.GoString(cstr)
 }
 
 func (d *ExifDatum) String() string {
+	if d.data.img.closed() {
+		return ""
+	}
+	defer runtime.KeepAlive(d)
+
 	cstr := C.exiv2_exif_datum_to_string(d.datum)
 	defer C.free(unsafe.Pointer(cstr))
 
 	return C.GoString(cstr)
 }
 
+// value returns the value of the datum.
+func (d *ExifDatum) value() *datumValue {
+	if d.data.img.closed() {
+		return &datumValue{img: d.data.img}
+	}
+
+	return &datumValue{
+		img:   d.data.img,
+		val:   C.exiv2_exif_datum_value(d.datum),
+		owner: d,
+	}
+}
+
+// TypeID returns the type of the datum value.
+func (d *ExifDatum) TypeID() TypeID {
+	return d.value().typeID()
+}
+
+// Count returns the number of components of the datum value.
+func (d *ExifDatum) Count() int {
+	return d.value().count()
+}
+
+// Size returns the size of the datum value in bytes.
+func (d *ExifDatum) Size() int {
+	return d.value().size()
+}
+
+// Int64 returns the n-th component of the datum value as an integer.
+func (d *ExifDatum) Int64(n int) (int64, error) {
+	return d.value().int64(n)
+}
+
+// Float64 returns the n-th component of the datum value as a float.
+func (d *ExifDatum) Float64(n int) (float64, error) {
+	return d.value().float64(n)
+}
+
+// Rational returns the n-th component of the datum value as a rational.
+func (d *ExifDatum) Rational(n int) (Rational, error) {
+	return d.value().rational(n)
+}
+
+// Bytes returns the raw data of the datum value. Multi-byte numbers are
+// encoded in little-endian byte order.
+func (d *ExifDatum) Bytes() []byte {
+	return d.value().bytes()
+}
+
+// Values returns every component of the datum value as a string. Text values
+// are returned as a single element.
+func (d *ExifDatum) Values() ([]string, error) {
+	return d.value().values()
+}
+
 // AllTags returns all EXIF tags
 func (d *ExifData) AllTags() map[string]string {
 	keyValues := map[string]string{}
-	for i := d.Iterator(); i.HasNext(); {
-		d := i.Next()
+	iter := d.Iterator()
+	defer iter.Close()
+
+	for iter.HasNext() {
+		d := iter.Next()
 		keyValues[d.Key()] = d.String()
 	}
 
 	return keyValues
 }
 
+// AllEntries returns all EXIF entries in the order they are stored, keeping
+// every entry of keys that occur more than once.
+func (d *ExifData) AllEntries() []Entry {
+	var entries []Entry
+	indexes := map[string]int{}
+
+	iter := d.Iterator()
+	defer iter.Close()
+
+	for iter.HasNext() {
+		datum := iter.Next()
+		key := datum.Key()
+
+		entries = append(entries, Entry{
+			Key:    key,
+			Value:  datum.String(),
+			TypeID: datum.TypeID(),
+			Index:  indexes[key],
+		})
+		indexes[key]++
+	}
+
+	return entries
+}
+
+// GetAll returns the values of every entry with the given key, in the order
+// they are stored.
+func (d *ExifData) GetAll(key string) ([]string, error) {
+	datum, err := d.FindKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if datum == nil {
+		return nil, ErrMetadataKeyNotFound
+	}
+
+	// FindKey has validated the key, now use its canonical form
+	key = datum.Key()
+
+	var values []string
+
+	iter := d.Iterator()
+	defer iter.Close()
+
+	for iter.HasNext() {
+		datum := iter.Next()
+		if datum.Key() == key {
+			values = append(values, datum.String())
+		}
+	}
+
+	return values, nil
+}
+
 // Iterator returns a new ExifDatumIterator to iterate over all Exif data.
 func (d *ExifData) Iterator() *ExifDatumIterator {
+	if d.closed() {
+		return &ExifDatumIterator{data: d}
+	}
+	defer runtime.KeepAlive(d)
+
 	return makeExifDatumIterator(d, C.exiv2_exif_data_iterator(d.data))
 }
 
 // HasNext returns true as long as the iterator has another datum to deliver.
 func (i *ExifDatumIterator) HasNext() bool {
+	if i.closed() {
+		return false
+	}
+	defer runtime.KeepAlive(i)
+
 	return C.exiv2_exif_data_iterator_has_next(i.iter) != 0
 }
 
 // Next returns the next ExifDatum of the iterator or nil if iterator has reached the end.
 func (i *ExifDatumIterator) Next() *ExifDatum {
+	if i.closed() {
+		return nil
+	}
+	defer runtime.KeepAlive(i)
+
 	return makeExifDatum(i.data, C.exiv2_exif_datum_iterator_next(i.iter))
 }
 
@@ -137,12 +312,32 @@ func makeExifDatumIterator(data *ExifData, cIter *C.Exiv2ExifDatumIterator) *Exi
 	datum := &ExifDatumIterator{data, cIter}
 
 	runtime.SetFinalizer(datum, func(i *ExifDatumIterator) {
-		C.exiv2_exif_datum_iterator_free(i.iter)
+		i.Close()
 	})
 
 	return datum
 }
 
+// Close frees the underlying C++ iterator. Calling Close more than once is a
+// no-op.
+func (i *ExifDatumIterator) Close() error {
+	if i.iter == nil {
+		return nil
+	}
+
+	C.exiv2_exif_datum_iterator_free(i.iter)
+	i.iter = nil
+	runtime.SetFinalizer(i, nil)
+
+	return nil
+}
+
+// closed reports whether the iterator or the image it belongs to has been
+// closed.
+func (i *ExifDatumIterator) closed() bool {
+	return i.iter == nil || i.data.img.closed()
+}
+
 // ExifStripKey removes the given key from the EXIF data.
 func (i *Image) ExifStripKey(key string) error {
 	return i.StripKey(EXIF, key)
@@ -150,9 +345,17 @@ func (i *Image) ExifStripKey(key string) error {
 
 // ExifStripMetadata removes all EXIF metadata except the keys in the unless array.
 func (i *Image) ExifStripMetadata(unless []string) error {
+	if i.closed() {
+		return ErrImageClosed
+	}
+	defer runtime.KeepAlive(i)
+
 	var cErr *C.Exiv2Error
 
-	tagsToRemove := getKeysToRemove(i.GetExifData(), unless)
+	data := i.GetExifData()
+	defer data.Close()
+
+	tagsToRemove := getKeysToRemove(data, unless)
 	if len(tagsToRemove) == 0 {
 		return nil
 	}
@@ -7,7 +7,10 @@ import "C"
 
 import (
 	"errors"
+	"fmt"
 	"runtime"
+	"runtime/cgo"
+	"strings"
 	"unsafe"
 )
 
@@ -19,12 +22,26 @@ type Error struct {
 type Image struct {
 	bytesArrayPtr unsafe.Pointer
 	img           *C.Exiv2Image
+	// reader is the handle of the io.ReaderAt an image opened with
+	// OpenReaderAt reads from.
+	reader cgo.Handle
 }
 
 type MetadataProvider interface {
 	GetString(key string) (string, error)
 }
 
+// Entry is a single metadata entry, as returned by AllEntries.
+type Entry struct {
+	Key    string
+	Value  string
+	TypeID TypeID
+	// Index is the position of the entry among the entries sharing its key,
+	// starting at 0. Repeatable IPTC datasets like Iptc.Application2.Keywords
+	// are the usual case of keys with more than one entry.
+	Index int
+}
+
 type MetadataFormat int
 
 const (
@@ -35,6 +52,10 @@ const (
 
 var ErrMetadataKeyNotFound = errors.New("key not found")
 
+// ErrImageClosed is returned when an Image, or a metadata handle obtained
+// from it, is used after Close has been called.
+var ErrImageClosed = errors.New("image closed")
+
 func (e *Error) Error() string {
 	return e.what
 }
@@ -56,17 +77,48 @@ func makeImage(cimg *C.Exiv2Image, bytesPtr unsafe.Pointer) *Image {
 		img:           cimg,
 	}
 
+	// The finalizer is only a safety net, callers should use Close to
+	// release the native memory deterministically.
 	runtime.SetFinalizer(img, func(x *Image) {
-		C.exiv2_image_free(x.img)
-
-		if x.bytesArrayPtr != nil {
-			C.free(x.bytesArrayPtr)
-		}
+		x.Close()
 	})
 
 	return img
 }
 
+// Close frees the underlying C++ image and the buffer backing an image
+// opened with OpenBytes, and releases the reader of an image opened with
+// OpenReaderAt. Any later call on the Image, or on metadata
+// obtained from it, returns ErrImageClosed. Calling Close more than once is
+// a no-op.
+func (i *Image) Close() error {
+	if i.img == nil {
+		return nil
+	}
+
+	C.exiv2_image_free(i.img)
+	i.img = nil
+
+	if i.bytesArrayPtr != nil {
+		C.free(i.bytesArrayPtr)
+		i.bytesArrayPtr = nil
+	}
+
+	if i.reader != 0 {
+		i.reader.Delete()
+		i.reader = 0
+	}
+
+	runtime.SetFinalizer(i, nil)
+
+	return nil
+}
+
+// closed reports whether Close has been called on the image.
+func (i *Image) closed() bool {
+	return i.img == nil
+}
+
 // Open opens an image file from the filesystem and returns a pointer to
 // the corresponding Image object, but does not read the Metadata.
 // Start the parsing with a call to ReadMetadata()
@@ -130,6 +182,11 @@ func SetLogMsgLevel(level LogMsgLevel) {
 
 // ReadMetadata reads the metadata of an Image
 func (i *Image) ReadMetadata() error {
+	if i.closed() {
+		return ErrImageClosed
+	}
+	defer runtime.KeepAlive(i)
+
 	var cerr *C.Exiv2Error
 
 	C.exiv2_image_read_metadata(i.img, &cerr)
@@ -145,25 +202,50 @@ func (i *Image) ReadMetadata() error {
 
 // GetBytes returns an image contents.
 // If its metadata has been changed, the changes are reflected here.
+// It returns nil if the image has been closed or its contents can't be read,
+// e.g. if the reader of OpenReaderAt fails.
 func (i *Image) GetBytes() []byte {
+	if i.closed() {
+		return nil
+	}
+	defer runtime.KeepAlive(i)
+
 	size := C.exiv_image_get_size(i.img)
 	ptr := C.exiv_image_get_bytes_ptr(i.img)
+	if ptr == nil {
+		return nil
+	}
 
 	return C.GoBytes(unsafe.Pointer(ptr), C.int(size))
 }
 
 // PixelWidth returns the width of the image in pixels
 func (i *Image) PixelWidth() int64 {
+	if i.closed() {
+		return 0
+	}
+	defer runtime.KeepAlive(i)
+
 	return int64(C.exiv2_image_get_pixel_width(i.img))
 }
 
 // PixelHeight returns the height of the image in pixels
 func (i *Image) PixelHeight() int64 {
+	if i.closed() {
+		return 0
+	}
+	defer runtime.KeepAlive(i)
+
 	return int64(C.exiv2_image_get_pixel_height(i.img))
 }
 
 // ICCProfile returns the ICC profile or nil if the image doesn't has one.
 func (i *Image) ICCProfile() []byte {
+	if i.closed() {
+		return nil
+	}
+	defer runtime.KeepAlive(i)
+
 	size := C.int(C.exiv2_image_icc_profile_size(i.img))
 	if size <= 0 {
 		return nil
@@ -171,111 +253,105 @@ func (i *Image) ICCProfile() []byte {
 	return C.GoBytes(unsafe.Pointer(C.exiv2_image_icc_profile(i.img)), size)
 }
 
+// Set sets a key to a value given as text. The metadata format is taken
+// from the key prefix ("Exif.", "Iptc." or "Xmp.") and the value is stored
+// with the type Exiv2's tag registry defines for the key, e.g. "1" is written
+// as a SHORT to Exif.Image.Orientation. Rationals may be given as fractions,
+// integers or decimals, e.g. "300" for Exif.Image.XResolution. An error is
+// returned if the text can't be parsed as that type.
+func (i *Image) Set(key, value string) error {
+	return i.edit(func(e *Editor) error {
+		return e.Set(key, value)
+	})
+}
+
 // SetMetadataString Sets an exif or iptc key with a given string value
 func (i *Image) SetMetadataString(f MetadataFormat, key, value string) error {
-	cKey := C.CString(key)
-	cValue := C.CString(value)
-
-	defer func() {
-		C.free(unsafe.Pointer(cKey))
-		C.free(unsafe.Pointer(cValue))
-	}()
-
-	var cerr *C.Exiv2Error
-
-	switch f {
-	case EXIF:
-		C.exiv2_image_set_exif_string(i.img, cKey, cValue, &cerr)
-	case IPTC:
-		C.exiv2_image_set_iptc_string(i.img, cKey, cValue, &cerr)
-	case XMP:
-		C.exiv2_image_set_xmp_string(i.img, cKey, cValue, &cerr)
-	default:
-		return errors.New("invalid metadata type")
-	}
-
-	if cerr != nil {
-		err := makeError(cerr)
-		C.exiv2_error_free(cerr)
-		return err
-	}
-
-	return nil
+	return i.edit(func(e *Editor) error {
+		return e.SetMetadataString(f, key, value)
+	})
 }
 
 // SetMetadataShort Sets an exif or iptc key with a given short value
 func (i *Image) SetMetadataShort(f MetadataFormat, key, value string) error {
-	cKey := C.CString(key)
-	cValue := C.CString(value)
-
-	defer func() {
-		C.free(unsafe.Pointer(cKey))
-		C.free(unsafe.Pointer(cValue))
-	}()
-
-	var cerr *C.Exiv2Error
-
-	switch f {
-	case EXIF:
-		C.exiv2_image_set_exif_short(i.img, cKey, cValue, &cerr)
-	case IPTC:
-		C.exiv2_image_set_iptc_short(i.img, cKey, cValue, &cerr)
-	default:
-		return errors.New("invalid metadata type")
-	}
-
-	if cerr != nil {
-		err := makeError(cerr)
-		C.exiv2_error_free(cerr)
-		return err
-	}
+	return i.edit(func(e *Editor) error {
+		return e.SetMetadataShort(f, key, value)
+	})
+}
 
-	return nil
+// SetValue sets a key of the given metadata format to a typed value
+func (i *Image) SetValue(f MetadataFormat, key string, v Value) error {
+	return i.edit(func(e *Editor) error {
+		return e.SetValue(f, key, v)
+	})
 }
 
 // StripKey removes a key from the metadata
 func (i *Image) StripKey(f MetadataFormat, key string) error {
-	ckey := C.CString(key)
-	defer C.free(unsafe.Pointer(ckey))
-
-	var cErr *C.Exiv2Error
-
-	switch f {
-	case EXIF:
-		C.exiv2_exif_strip_key(i.img, ckey, &cErr)
-	case IPTC:
-		C.exiv2_iptc_strip_key(i.img, ckey, &cErr)
-	case XMP:
-		C.exiv2_xmp_strip_key(i.img, ckey, &cErr)
-	default:
-		return errors.New("invalid metadata format")
-	}
+	return i.edit(func(e *Editor) error {
+		return e.StripKey(f, key)
+	})
+}
 
-	if cErr != nil {
-		err := makeError(cErr)
-		C.exiv2_error_free(cErr)
-		return err
+// StripMetadata removes all metadata from the image except the keys in
+// unless. The image comment is removed as well. The image is written once,
+// and left untouched if the write fails.
+func (i *Image) StripMetadata(unless []string) error {
+	if i.closed() {
+		return ErrImageClosed
 	}
 
-	return nil
+	exifData := i.GetExifData()
+	defer exifData.Close()
+	iptcData := i.GetIptcData()
+	defer iptcData.Close()
+	xmpData := i.GetXmpData()
+	defer xmpData.Close()
+
+	return i.edit(func(e *Editor) error {
+		for _, key := range getKeysToRemove(exifData, unless) {
+			if err := e.ExifStripKey(key); err != nil {
+				return err
+			}
+		}
+		// repeatable datasets may occur several times, remove them all
+		for _, key := range getKeysToRemove(iptcData, unless) {
+			if err := e.DeleteIptcAll(key); err != nil {
+				return err
+			}
+		}
+		for _, key := range getKeysToRemove(xmpData, unless) {
+			if err := e.XmpStripKey(key); err != nil {
+				return err
+			}
+		}
+		if i.Comment() != "" {
+			return e.ClearComment()
+		}
+		return nil
+	})
 }
 
-// StripMetadata removes all metadata from the image except the keys in unless
-func (i *Image) StripMetadata(unless []string) error {
-	var err error
-	err = i.ExifStripMetadata(unless)
-	if err != nil {
-		return err
-	}
-	err = i.IptcStripMetadata(unless)
-	if err != nil {
-		return err
+// formatOfKey returns the metadata format of a key from its prefix.
+func formatOfKey(key string) (MetadataFormat, error) {
+	switch {
+	case strings.HasPrefix(key, "Exif."):
+		return EXIF, nil
+	case strings.HasPrefix(key, "Iptc."):
+		return IPTC, nil
+	case strings.HasPrefix(key, "Xmp."):
+		return XMP, nil
 	}
-	err = i.XmpStripMetadata(unless)
-	if err != nil {
-		return err
+
+	return 0, fmt.Errorf("cannot infer metadata format of key %q", key)
+}
+
+// cBool converts a Go bool to a C int.
+func cBool(b bool) C.int {
+	if b {
+		return 1
 	}
-	return nil
+	return 0
 }
 
 // contains checks if a string is present in a string slice
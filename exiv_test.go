@@ -1,13 +1,19 @@
 package goexiv_test
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"github.com/rtio/goexiv"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestOpenImage(t *testing.T) {
@@ -496,21 +502,1571 @@ func Test_GetBytes_Goroutine(t *testing.T) {
 			// trigger garbage collection to increase the chance that underlying img.img will be collected
 			runtime.GC()
 
+			// GetBytes keeps img alive until the C++ calls return, so
+			// no runtime.KeepAlive is needed here.
 			bytesAfter := img.GetBytes()
 			assert.NotEmpty(t, bytesAfter)
+		}(i)
+	}
+
+	wg.Wait()
+	runtime.GC()
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	t.Logf("Allocated bytes after test:  %+v\n", memStats.HeapAlloc)
+}
+
+func TestImage_Close(t *testing.T) {
+	img, err := goexiv.Open("testdata/pixel.jpg")
+	require.NoError(t, err)
+	require.NoError(t, img.ReadMetadata())
+
+	exifData := img.GetExifData()
+	iptcData := img.GetIptcData()
+	xmpData := img.GetXmpData()
+	iter := exifData.Iterator()
+
+	require.NoError(t, img.Close())
+	// closing twice is a no-op
+	require.NoError(t, img.Close())
+
+	assert.Equal(t, goexiv.ErrImageClosed, img.ReadMetadata())
+	assert.Equal(t, goexiv.ErrImageClosed, img.SetExifString("Exif.Photo.UserComment", "123"))
+	assert.Equal(t, goexiv.ErrImageClosed, img.SetMetadataShort(goexiv.EXIF, "Exif.Photo.ExposureProgram", "1"))
+	assert.Equal(t, goexiv.ErrImageClosed, img.StripKey(goexiv.EXIF, "Exif.Image.Make"))
+	assert.Equal(t, goexiv.ErrImageClosed, img.StripMetadata(nil))
+	assert.Nil(t, img.GetBytes())
+	assert.Nil(t, img.ICCProfile())
+	assert.Zero(t, img.PixelWidth())
+	assert.Zero(t, img.PixelHeight())
+
+	_, err = exifData.GetString("Exif.Image.Make")
+	assert.Equal(t, goexiv.ErrImageClosed, err)
+	_, err = iptcData.GetString("Iptc.Application2.Copyright")
+	assert.Equal(t, goexiv.ErrImageClosed, err)
+	_, err = xmpData.GetString("Xmp.iptc.CreditLine")
+	assert.Equal(t, goexiv.ErrImageClosed, err)
+	assert.False(t, iter.HasNext())
+	assert.Nil(t, iter.Next())
+	assert.Empty(t, img.GetExifData().AllTags())
+
+	assert.NoError(t, exifData.Close())
+	assert.NoError(t, iptcData.Close())
+	assert.NoError(t, xmpData.Close())
+	assert.NoError(t, iter.Close())
+}
+
+func TestExifData_Close(t *testing.T) {
+	initializeImage("testdata/pixel.jpg", t)
+	img, err := goexiv.Open("testdata/pixel.jpg")
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+
+	data := img.GetExifData()
+	datum, err := data.FindKey("Exif.Image.Make")
+	require.NoError(t, err)
+
+	require.NoError(t, data.Close())
+	_, err = data.FindKey("Exif.Image.Make")
+	assert.Equal(t, goexiv.ErrImageClosed, err)
+
+	// datums stay valid as long as the image is open
+	assert.Equal(t, "FakeMake", datum.String())
+
+	// a fresh handle can still be obtained from the image
+	value, err := img.GetExifData().GetString("Exif.Image.Make")
+	require.NoError(t, err)
+	assert.Equal(t, "FakeMake", value)
+}
+
+func TestEditor_Commit(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+
+	e, err := img.Begin()
+	require.NoError(t, err)
+
+	require.NoError(t, e.SetExifString("Exif.Image.Make", "FakeMake"))
+	require.NoError(t, e.SetMetadataShort(goexiv.EXIF, "Exif.Photo.ExposureProgram", "2"))
+	require.NoError(t, e.SetIptcString("Iptc.Application2.Caption", "A caption"))
+	require.NoError(t, e.SetXmpString("Xmp.iptc.CreditLine", "John Doe"))
+	require.NoError(t, e.SetExifString("Exif.Image.Model", "FakeModel"))
+	require.NoError(t, e.ExifStripKey("Exif.Image.Model"))
+
+	// a failing change does not abort the whole edit
+	assert.Error(t, e.SetExifString("Exif.Invalid.Key", "value"))
+
+	assert.Equal(t, len(bytes), len(img.GetBytes()), "Nothing must be written before Commit")
+
+	require.NoError(t, e.Commit())
+	assert.Equal(t, goexiv.ErrEditFinished, e.Commit())
+	assert.Equal(t, goexiv.ErrEditFinished, e.SetExifString("Exif.Image.Make", "FakeMake"))
+	assert.Equal(t, goexiv.ErrEditFinished, e.Rollback())
+
+	require.NoError(t, img.ReadMetadata())
+	exifData := img.GetExifData()
+	value, err := exifData.GetString("Exif.Image.Make")
+	require.NoError(t, err)
+	assert.Equal(t, "FakeMake", value)
+	value, err = exifData.GetString("Exif.Photo.ExposureProgram")
+	require.NoError(t, err)
+	assert.Equal(t, "2", value)
+	_, err = exifData.GetString("Exif.Image.Model")
+	assert.Equal(t, goexiv.ErrMetadataKeyNotFound, err)
+
+	assert.Equal(t, map[string]string{
+		"Iptc.Application2.Caption": "A caption",
+	}, img.GetIptcData().AllTags())
+	assert.Equal(t, map[string]string{
+		"Xmp.iptc.CreditLine": "John Doe",
+	}, img.GetXmpData().AllTags())
+}
+
+func TestEditor_Rollback(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+
+	e, err := img.Begin()
+	require.NoError(t, err)
+
+	require.NoError(t, e.SetExifString("Exif.Image.Make", "FakeMake"))
+	require.NoError(t, e.SetIptcString("Iptc.Application2.Caption", "A caption"))
+	require.NoError(t, e.Rollback())
+	assert.Equal(t, goexiv.ErrEditFinished, e.Commit())
+
+	require.NoError(t, img.ReadMetadata())
+	assert.Empty(t, img.GetExifData().AllTags())
+	assert.Empty(t, img.GetIptcData().AllTags())
+	assert.Equal(t, bytes, img.GetBytes())
+}
+
+func TestEditor_CommitFailed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pixel.jpg")
+	data, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	img, err := goexiv.Open(path)
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+
+	// the write fails as the file is gone
+	require.NoError(t, os.Remove(path))
+
+	e, err := img.Begin()
+	require.NoError(t, err)
+	require.NoError(t, e.SetExifString("Exif.Image.Make", "OtherMake"))
+	require.NoError(t, e.SetIptcString("Iptc.Application2.Caption", "A caption"))
+	require.NoError(t, e.SetComment("A comment"))
+	assert.Error(t, e.Commit())
+
+	// the image keeps its metadata, no change is half applied
+	value, err := img.GetExifData().GetString("Exif.Image.Make")
+	require.NoError(t, err)
+	assert.Equal(t, "FakeMake", value)
+	assert.NotContains(t, img.GetIptcData().AllTags(), "Iptc.Application2.Caption")
+	assert.Empty(t, img.Comment())
+}
+
+func TestEditor_CommitFailedTiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pixel.tif")
+	data, err := os.ReadFile("testdata/pixel.tif")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	img, err := goexiv.Open(path)
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+
+	// TIFF images have no comment
+	assert.Error(t, img.SetComment("A comment"))
+
+	// the write fails as the file is replaced by a directory
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, os.Mkdir(path, 0o755))
+
+	e, err := img.Begin()
+	require.NoError(t, err)
+	require.NoError(t, e.SetExifString("Exif.Image.Make", "OtherMake"))
+	require.NoError(t, e.SetXmpString("Xmp.dc.title", "A title"))
+	require.NoError(t, e.ClearComment())
+	assert.Error(t, e.Commit())
+
+	value, err := img.GetExifData().GetString("Exif.Image.Make")
+	require.NoError(t, err)
+	assert.Equal(t, "FakeMake", value)
+	assert.NotContains(t, img.GetXmpData().AllTags(), "Xmp.dc.title")
+}
+
+func TestEditor_ImageClosed(t *testing.T) {
+	img, err := goexiv.Open("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	e, err := img.Begin()
+	require.NoError(t, err)
+	require.NoError(t, img.Close())
+
+	assert.Equal(t, goexiv.ErrImageClosed, e.SetExifString("Exif.Image.Make", "FakeMake"))
+	assert.Equal(t, goexiv.ErrImageClosed, e.Commit())
+	assert.NoError(t, e.Rollback())
+
+	_, err = img.Begin()
+	assert.Equal(t, goexiv.ErrImageClosed, err)
+}
+
+func TestDatum_TypedValues(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+
+	e, err := img.Begin()
+	require.NoError(t, err)
+	require.NoError(t, e.SetExifString("Exif.Image.Make", "FakeMake"))
+	require.NoError(t, e.SetMetadataShort(goexiv.EXIF, "Exif.Photo.ISOSpeedRatings", "100 200"))
+	require.NoError(t, e.SetIptcString("Iptc.Application2.Caption", "A caption"))
+	require.NoError(t, e.SetXmpString("Xmp.iptc.CreditLine", "John Doe"))
+	require.NoError(t, e.Commit())
+	require.NoError(t, img.ReadMetadata())
+
+	// EXIF short array
+	datum, err := img.GetExifData().FindKey("Exif.Photo.ISOSpeedRatings")
+	require.NoError(t, err)
+	require.NotNil(t, datum)
+
+	assert.Equal(t, goexiv.TypeUnsignedShort, datum.TypeID())
+	assert.Equal(t, "Short", datum.TypeID().String())
+	assert.Equal(t, 2, datum.Count())
+	assert.Equal(t, 4, datum.Size())
+	assert.Equal(t, []byte{100, 0, 200, 0}, datum.Bytes())
+
+	i, err := datum.Int64(1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), i)
+
+	f, err := datum.Float64(0)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, f)
+
+	r, err := datum.Rational(1)
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.Rational{Numerator: 200, Denominator: 1}, r)
+
+	values, err := datum.Values()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"100", "200"}, values)
+
+	_, err = datum.Int64(2)
+	assert.EqualError(t, err, "value index out of range")
+	_, err = datum.Int64(-1)
+	assert.EqualError(t, err, "value index out of range")
+
+	// EXIF ascii string
+	datum, err = img.GetExifData().FindKey("Exif.Image.Make")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.TypeAsciiString, datum.TypeID())
+	values, err = datum.Values()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FakeMake"}, values)
+
+	// IPTC string
+	iptcDatum, err := img.GetIptcData().FindKey("Iptc.Application2.Caption")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.TypeString, iptcDatum.TypeID())
+	assert.Equal(t, []byte("A caption"), iptcDatum.Bytes())
+	values, err = iptcDatum.Values()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A caption"}, values)
+
+	// XMP text
+	xmpDatum, err := img.GetXmpData().FindKey("Xmp.iptc.CreditLine")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.TypeXmpText, xmpDatum.TypeID())
+	assert.Equal(t, "XmpText", xmpDatum.TypeID().String())
+	values, err = xmpDatum.Values()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"John Doe"}, values)
+
+	// closed image
+	require.NoError(t, img.Close())
+	assert.Equal(t, goexiv.TypeInvalid, datum.TypeID())
+	assert.Zero(t, datum.Count())
+	_, err = datum.Int64(0)
+	assert.Equal(t, goexiv.ErrImageClosed, err)
+}
+
+func TestDatum_ValueAfterCommit(t *testing.T) {
+	data, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(data)
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+
+	datum, err := img.GetExifData().FindKey("Exif.Photo.ColorSpace")
+	require.NoError(t, err)
+	require.NotNil(t, datum)
+
+	// the commit replaces the entries of the image metadata, the datum
+	// keeps the entry it was created with
+	require.NoError(t, img.SetExifValue("Exif.Photo.ColorSpace", goexiv.NewUnsignedShortValue(1)))
+	require.NoError(t, img.ExifStripKey("Exif.Image.Make"))
+
+	assert.Equal(t, "Exif.Photo.ColorSpace", datum.Key())
+	assert.Equal(t, "65535", datum.String())
+	assert.Equal(t, goexiv.TypeUnsignedShort, datum.TypeID())
+	v, err := datum.Int64(0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(65535), v)
+
+	datum, err = img.GetExifData().FindKey("Exif.Photo.ColorSpace")
+	require.NoError(t, err)
+	assert.Equal(t, "1", datum.String())
+	v, err = datum.Int64(0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+}
+
+func TestRational(t *testing.T) {
+	r := goexiv.Rational{Numerator: 1, Denominator: 250}
+	assert.Equal(t, 0.004, r.Float64())
+	assert.Equal(t, "1/250", r.String())
+}
+
+func TestSetValue(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+
+	created := time.Date(2012, 10, 13, 12, 49, 32, 0, time.FixedZone("", 3600))
+
+	e, err := img.Begin()
+	require.NoError(t, err)
+	require.NoError(t, e.SetExifValue("Exif.Image.XResolution", goexiv.NewUnsignedRationalValue(goexiv.Rational{Numerator: 300, Denominator: 1})))
+	require.NoError(t, e.SetExifValue("Exif.GPSInfo.GPSLatitude", goexiv.NewUnsignedRationalValue(
+		goexiv.Rational{Numerator: 52, Denominator: 1},
+		goexiv.Rational{Numerator: 31, Denominator: 1},
+		goexiv.Rational{Numerator: 1234, Denominator: 100},
+	)))
+	require.NoError(t, e.SetExifValue("Exif.Photo.ExposureBiasValue", goexiv.NewSignedRationalValue(goexiv.Rational{Numerator: -1, Denominator: 3})))
+	require.NoError(t, e.SetExifValue("Exif.Photo.ExifVersion", goexiv.NewUndefinedValue([]byte("0230"))))
+	require.NoError(t, e.SetExifValue("Exif.Photo.PixelXDimension", goexiv.NewUnsignedLongValue(4000)))
+	require.NoError(t, e.SetExifValue("Exif.Photo.UserComment", goexiv.NewCommentValue(goexiv.CharsetASCII, "A comment")))
+	require.NoError(t, e.SetIptcValue("Iptc.Application2.DateCreated", goexiv.NewDateValue(created)))
+	require.NoError(t, e.SetIptcValue("Iptc.Application2.TimeCreated", goexiv.NewTimeValue(created)))
+	require.NoError(t, e.Commit())
+	require.NoError(t, img.ReadMetadata())
+
+	exifData := img.GetExifData()
+
+	datum, err := exifData.FindKey("Exif.Image.XResolution")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.TypeUnsignedRational, datum.TypeID())
+	r, err := datum.Rational(0)
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.Rational{Numerator: 300, Denominator: 1}, r)
+
+	datum, err = exifData.FindKey("Exif.GPSInfo.GPSLatitude")
+	require.NoError(t, err)
+	assert.Equal(t, 3, datum.Count())
+	f, err := datum.Float64(2)
+	require.NoError(t, err)
+	assert.Equal(t, 12.34, f)
+
+	datum, err = exifData.FindKey("Exif.Photo.ExposureBiasValue")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.TypeSignedRational, datum.TypeID())
+	r, err = datum.Rational(0)
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.Rational{Numerator: -1, Denominator: 3}, r)
+
+	datum, err = exifData.FindKey("Exif.Photo.ExifVersion")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.TypeUndefined, datum.TypeID())
+	assert.Equal(t, []byte("0230"), datum.Bytes())
+
+	datum, err = exifData.FindKey("Exif.Photo.PixelXDimension")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.TypeUnsignedLong, datum.TypeID())
+	i, err := datum.Int64(0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4000), i)
+
+	// comments are stored with an 8 byte character set header
+	datum, err = exifData.FindKey("Exif.Photo.UserComment")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("ASCII\x00\x00\x00A comment"), datum.Bytes())
+
+	iptcData := img.GetIptcData()
+	date, err := iptcData.GetString("Iptc.Application2.DateCreated")
+	require.NoError(t, err)
+	assert.Equal(t, "2012-10-13", date)
+	tm, err := iptcData.GetString("Iptc.Application2.TimeCreated")
+	require.NoError(t, err)
+	assert.Equal(t, "12:49:32+01:00", tm)
+}
+
+func TestSetValue_Invalid(t *testing.T) {
+	img, err := goexiv.Open("testdata/pixel.jpg")
+	require.NoError(t, err)
+	defer img.Close()
+
+	err = img.SetExifValue("Exif.Image.XResolution", goexiv.NewUnsignedRationalValue(goexiv.Rational{Numerator: -1, Denominator: 1}))
+	assert.EqualError(t, err, "rational -1/1 out of range for type Rational")
+
+	err = img.SetExifValue("Exif.Image.Orientation", goexiv.NewValue(goexiv.TypeUnsignedShort, "not a number"))
+	assert.EqualError(t, err, "invalid value for type Short")
+
+	err = img.SetValue(999, "Exif.Image.Orientation", goexiv.NewUnsignedShortValue(1))
+	assert.EqualError(t, err, "invalid metadata type")
+}
+
+func TestDefaultTypeID(t *testing.T) {
+	tests := []struct {
+		format goexiv.MetadataFormat
+		key    string
+		want   goexiv.TypeID
+	}{
+		{goexiv.EXIF, "Exif.Image.Orientation", goexiv.TypeUnsignedShort},
+		{goexiv.EXIF, "Exif.Image.XResolution", goexiv.TypeUnsignedRational},
+		{goexiv.EXIF, "Exif.Image.Make", goexiv.TypeAsciiString},
+		{goexiv.IPTC, "Iptc.Application2.Keywords", goexiv.TypeString},
+		{goexiv.IPTC, "Iptc.Application2.DateCreated", goexiv.TypeDate},
+		{goexiv.XMP, "Xmp.dc.subject", goexiv.TypeXmpBag},
+		{goexiv.XMP, "Xmp.dc.title", goexiv.TypeLangAlt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			typeID, err := goexiv.DefaultTypeID(tt.format, tt.key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, typeID)
+		})
+	}
+
+	_, err := goexiv.DefaultTypeID(goexiv.EXIF, "Exif.Invalid.Key")
+	assert.Error(t, err)
+}
+
+func TestImage_Set(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+
+	require.NoError(t, img.Set("Exif.Image.Orientation", "6"))
+	require.NoError(t, img.Set("Exif.Image.XResolution", "300/1"))
+	require.NoError(t, img.Set("Iptc.Application2.Keywords", "pixel"))
+	require.NoError(t, img.Set("Xmp.dc.subject", "pixel"))
+	require.NoError(t, img.ReadMetadata())
+
+	datum, err := img.GetExifData().FindKey("Exif.Image.Orientation")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.TypeUnsignedShort, datum.TypeID())
+	assert.Equal(t, "6", datum.String())
+
+	datum, err = img.GetExifData().FindKey("Exif.Image.XResolution")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.TypeUnsignedRational, datum.TypeID())
+
+	// rationals may be given as integers or decimals
+	require.NoError(t, img.Set("Exif.Image.XResolution", "300"))
+	require.NoError(t, img.Set("Exif.Photo.FNumber", "2.8"))
+	require.NoError(t, img.Set("Exif.Photo.ExposureBiasValue", "-0.5"))
+	require.NoError(t, img.ReadMetadata())
+	tags := img.GetExifData().AllTags()
+	assert.Equal(t, "300/1", tags["Exif.Image.XResolution"])
+	assert.Equal(t, "14/5", tags["Exif.Photo.FNumber"])
+	assert.Equal(t, "-1/2", tags["Exif.Photo.ExposureBiasValue"])
+
+	iptcDatum, err := img.GetIptcData().FindKey("Iptc.Application2.Keywords")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.TypeString, iptcDatum.TypeID())
+
+	xmpDatum, err := img.GetXmpData().FindKey("Xmp.dc.subject")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.TypeXmpBag, xmpDatum.TypeID())
+
+	assert.EqualError(t, img.Set("Exif.Image.Orientation", "upside down"), "invalid value for type Short")
+	assert.EqualError(t, img.Set("Exif.Image.XResolution", "a lot"), "invalid value for type Rational")
+	assert.EqualError(t, img.Set("Orientation", "1"), `cannot infer metadata format of key "Orientation"`)
+}
+
+func TestAllEntries(t *testing.T) {
+	initializeImage("testdata/pixel.jpg", t)
+	img, err := goexiv.Open("testdata/pixel.jpg")
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+
+	exifData := img.GetExifData()
+	iptcData := img.GetIptcData()
+	xmpData := img.GetXmpData()
+
+	for _, tc := range []struct {
+		entries []goexiv.Entry
+		tags    map[string]string
+	}{
+		{exifData.AllEntries(), exifData.AllTags()},
+		{iptcData.AllEntries(), iptcData.AllTags()},
+		{xmpData.AllEntries(), xmpData.AllTags()},
+	} {
+		require.Len(t, tc.entries, len(tc.tags))
+		for _, entry := range tc.entries {
+			assert.Equal(t, tc.tags[entry.Key], entry.Value, entry.Key)
+			assert.Equal(t, 0, entry.Index, entry.Key)
+			assert.NotEqual(t, goexiv.TypeInvalid, entry.TypeID, entry.Key)
+		}
+	}
+
+	values, err := iptcData.GetAll("Iptc.Application2.CountryName")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Lancre"}, values)
+
+	values, err = exifData.GetAll("Exif.Image.Make")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FakeMake"}, values)
+
+	values, err = xmpData.GetAll("Xmp.iptc.JobId")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"12345"}, values)
+
+	_, err = iptcData.GetAll("Iptc.Application2.Keywords")
+	assert.Equal(t, goexiv.ErrMetadataKeyNotFound, err)
+
+	_, err = iptcData.GetAll("Iptc.Invalid.Key")
+	assert.Error(t, err)
+}
+
+func TestRepeatableIptc(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+
+	require.NoError(t, img.SetIptcStrings("Iptc.Application2.Keywords", []string{"one", "two", "three"}))
+	require.NoError(t, img.AddIptcString("Iptc.Application2.Keywords", "four"))
+	require.NoError(t, img.SetIptcString("Iptc.Application2.Caption", "A caption"))
+	require.NoError(t, img.ReadMetadata())
+
+	keywords, err := img.GetIptcData().GetAll("Iptc.Application2.Keywords")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two", "three", "four"}, keywords)
+
+	var indexes []int
+	for _, entry := range img.GetIptcData().AllEntries() {
+		if entry.Key == "Iptc.Application2.Keywords" {
+			indexes = append(indexes, entry.Index)
+		}
+	}
+	assert.Equal(t, []int{0, 1, 2, 3}, indexes)
+
+	// non repeatable datasets can't have a second entry
+	err = img.AddIptcString("Iptc.Application2.Caption", "Another caption")
+	assert.EqualError(t, err, "Iptc.Application2.Caption is not repeatable")
+	err = img.SetIptcStrings("Iptc.Application2.Caption", []string{"One", "Two"})
+	assert.EqualError(t, err, "Iptc.Application2.Caption is not repeatable")
+
+	require.NoError(t, img.ReadMetadata())
+	captions, err := img.GetIptcData().GetAll("Iptc.Application2.Caption")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A caption"}, captions)
+
+	// replacing the list removes the previous entries
+	require.NoError(t, img.SetIptcStrings("Iptc.Application2.Keywords", []string{"five"}))
+	require.NoError(t, img.ReadMetadata())
+	keywords, err = img.GetIptcData().GetAll("Iptc.Application2.Keywords")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"five"}, keywords)
+
+	require.NoError(t, img.DeleteIptcAll("Iptc.Application2.Keywords"))
+	require.NoError(t, img.ReadMetadata())
+	_, err = img.GetIptcData().GetAll("Iptc.Application2.Keywords")
+	assert.Equal(t, goexiv.ErrMetadataKeyNotFound, err)
+}
+
+func TestIptcStripMetadata_Repeatable(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+
+	require.NoError(t, img.SetIptcStrings("Iptc.Application2.Keywords", []string{"one", "two", "three"}))
+	require.NoError(t, img.SetIptcString("Iptc.Application2.Caption", "A caption"))
+	require.NoError(t, img.IptcStripMetadata([]string{"Iptc.Application2.Caption"}))
+	require.NoError(t, img.ReadMetadata())
+
+	assert.Equal(t, map[string]string{
+		"Iptc.Application2.Caption": "A caption",
+	}, img.GetIptcData().AllTags())
+}
+
+func TestXmpArraysAndStructs(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+
+	e, err := img.Begin()
+	require.NoError(t, err)
+	require.NoError(t, e.SetXmpArray("Xmp.dc.subject", goexiv.TypeXmpBag, []string{"pixel", "test", "jpeg"}))
+	require.NoError(t, e.SetXmpArray("Xmp.dc.creator", goexiv.TypeXmpSeq, []string{"John Doe", "Jane Doe"}))
+	require.NoError(t, e.SetLangAlt("Xmp.dc.title", "x-default", "A pixel"))
+	require.NoError(t, e.SetLangAlt("Xmp.dc.title", "de-DE", "Ein Pixel"))
+	require.NoError(t, e.SetXmpStruct("Xmp.iptc.CreatorContactInfo", map[string]string{
+		"Iptc4xmpCore:CiEmailWork": "john@example.com",
+		"Iptc4xmpCore:CiAdrCity":   "Ankh-Morpork",
+	}))
+	require.NoError(t, e.Commit())
+
+	// a language can be replaced without touching the other ones
+	require.NoError(t, img.SetLangAlt("Xmp.dc.title", "de-DE", "Ein einzelnes Pixel"))
+	require.NoError(t, img.ReadMetadata())
+
+	data := img.GetXmpData()
+
+	subject, err := data.GetArray("Xmp.dc.subject")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pixel", "test", "jpeg"}, subject)
+
+	datum, err := data.FindKey("Xmp.dc.creator")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.TypeXmpSeq, datum.TypeID())
+	creator, err := data.GetArray("Xmp.dc.creator")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"John Doe", "Jane Doe"}, creator)
+
+	title, err := data.GetLangAlt("Xmp.dc.title")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"x-default": "A pixel",
+		"de-DE":     "Ein einzelnes Pixel",
+	}, title)
+
+	contact, err := data.GetStruct("Xmp.iptc.CreatorContactInfo")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"Iptc4xmpCore:CiEmailWork": "john@example.com",
+		"Iptc4xmpCore:CiAdrCity":   "Ankh-Morpork",
+	}, contact)
+
+	email, err := data.GetString(goexiv.XmpStructField("Xmp.iptc.CreatorContactInfo", "Iptc4xmpCore:CiEmailWork"))
+	require.NoError(t, err)
+	assert.Equal(t, "john@example.com", email)
+
+	_, err = data.GetLangAlt("Xmp.dc.subject")
+	assert.EqualError(t, err, "XmpBag value is not a language alternative")
+	_, err = data.GetStruct("Xmp.iptc.Location")
+	assert.Equal(t, goexiv.ErrMetadataKeyNotFound, err)
+
+	err = img.SetXmpArray("Xmp.dc.subject", goexiv.TypeXmpText, []string{"pixel"})
+	assert.EqualError(t, err, "XmpText is not an XMP array type")
+}
+
+func TestXmpKeyHelpers(t *testing.T) {
+	assert.Equal(t, "Xmp.iptc.CreatorContactInfo/Iptc4xmpCore:CiAdrCity", goexiv.XmpStructField("Xmp.iptc.CreatorContactInfo", "Iptc4xmpCore:CiAdrCity"))
+	assert.Equal(t, "Xmp.xmpMM.History[2]", goexiv.XmpArrayItem("Xmp.xmpMM.History", 2))
+	assert.Equal(t, "Xmp.xmpMM.History[2]/stEvt:action", goexiv.XmpStructField(goexiv.XmpArrayItem("Xmp.xmpMM.History", 2), "stEvt:action"))
+}
+
+func TestXmpNamespaces(t *testing.T) {
+	const uri = "https://example.com/ourco/1.0/"
+
+	require.NoError(t, goexiv.RegisterXmpNamespace(uri, "ourco"))
+	assert.Equal(t, uri, goexiv.XmpNamespaces()["ourco"])
+	// Exiv2 appends a trailing '/' to the URI
+	require.NoError(t, goexiv.RegisterXmpNamespace("https://example.com/other", "other"))
+	assert.Equal(t, "https://example.com/other/", goexiv.XmpNamespaces()["other"])
+	require.NoError(t, goexiv.UnregisterXmpNamespace("other"))
+	assert.Equal(t, "http://purl.org/dc/elements/1.1/", goexiv.XmpNamespaces()["dc"])
+
+	bytes, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+
+	require.NoError(t, img.SetXmpString("Xmp.ourco.AssetID", "A-1234"))
+	require.NoError(t, img.ReadMetadata())
+
+	datum, err := img.GetXmpData().FindKey("Xmp.ourco.AssetID")
+	require.NoError(t, err)
+	require.NotNil(t, datum)
+	assert.Equal(t, "A-1234", datum.String())
+
+	require.NoError(t, goexiv.UnregisterXmpNamespace("ourco"))
+	_, ok := goexiv.XmpNamespaces()["ourco"]
+	assert.False(t, ok)
+
+	assert.Error(t, goexiv.UnregisterXmpNamespace("ourco"))
+	assert.Error(t, goexiv.RegisterXmpNamespace("", "ourco"))
+
+	// built-in namespaces can't be removed
+	assert.Error(t, goexiv.UnregisterXmpNamespace("dc"))
+	assert.Equal(t, "http://purl.org/dc/elements/1.1/", goexiv.XmpNamespaces()["dc"])
+}
+
+func TestXmpPacket(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+
+	require.NoError(t, img.SetXmpString("Xmp.dc.format", "image/jpeg"))
+	require.NoError(t, img.ReadMetadata())
+
+	packet, err := img.XmpPacket()
+	require.NoError(t, err)
+	assert.Contains(t, string(packet), "image/jpeg")
+
+	data, err := goexiv.ParseXmp(packet)
+	require.NoError(t, err)
+	defer data.Close()
+	datum, err := data.FindKey("Xmp.dc.format")
+	require.NoError(t, err)
+	require.NotNil(t, datum)
+	assert.Equal(t, "image/jpeg", datum.String())
+
+	serialized, err := goexiv.SerializeXmp(data, goexiv.XmpSerializeOptions{
+		Flags: goexiv.XmpOmitPacketWrapper | goexiv.XmpUseCompactFormat,
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, string(serialized), "<?xpacket")
+	assert.Contains(t, string(serialized), "image/jpeg")
+
+	// closing the data frees the parsed packet
+	require.NoError(t, data.Close())
+	_, err = data.FindKey("Xmp.dc.format")
+	assert.Equal(t, goexiv.ErrImageClosed, err)
+
+	// a packet produced elsewhere is written as is
+	injected := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmp:CreatorTool="other tool"/>` +
+		`</rdf:RDF></x:xmpmeta>`)
+	require.NoError(t, img.SetXmpPacket(injected))
+	require.NoError(t, img.ReadMetadata())
+
+	packet, err = img.XmpPacket()
+	require.NoError(t, err)
+	assert.Equal(t, injected, packet)
+
+	datum, err = img.GetXmpData().FindKey("Xmp.xmp.CreatorTool")
+	require.NoError(t, err)
+	require.NotNil(t, datum)
+	assert.Equal(t, "other tool", datum.String())
 
-			// if this line is removed, then the test will likely fail
-			// with segmentation violation.
-			// so far we couldn't come up with a better solution.
-			runtime.KeepAlive(img)
-		}(i)
+	_, err = goexiv.ParseXmp([]byte("not xmp"))
+	assert.Error(t, err)
+	assert.Error(t, img.SetXmpPacket([]byte("not xmp")))
+}
+
+func TestSidecar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pixel.xmp")
+
+	sidecar, err := goexiv.NewSidecar()
+	require.NoError(t, err)
+	defer sidecar.Close()
+
+	require.NoError(t, sidecar.SetXmpString("Xmp.xmp.Rating", "4"))
+	require.NoError(t, sidecar.SetXmpString("Xmp.dc.format", "image/jpeg"))
+	require.NoError(t, sidecar.WriteSidecar(path))
+
+	opened, err := goexiv.OpenSidecar(path)
+	require.NoError(t, err)
+	defer opened.Close()
+	require.NoError(t, opened.ReadMetadata())
+
+	datum, err := opened.GetXmpData().FindKey("Xmp.xmp.Rating")
+	require.NoError(t, err)
+	require.NotNil(t, datum)
+	assert.Equal(t, "4", datum.String())
+
+	bytes, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+
+	require.NoError(t, img.SetXmpString("Xmp.xmp.Rating", "1"))
+	require.NoError(t, img.MergeSidecar(path))
+	require.NoError(t, img.ReadMetadata())
+
+	data := img.GetXmpData()
+	datum, err = data.FindKey("Xmp.xmp.Rating")
+	require.NoError(t, err)
+	require.NotNil(t, datum)
+	assert.Equal(t, "4", datum.String())
+
+	datum, err = data.FindKey("Xmp.dc.format")
+	require.NoError(t, err)
+	require.NotNil(t, datum)
+	assert.Equal(t, "image/jpeg", datum.String())
+
+	// the EXIF data of an image is converted when written to a sidecar
+	require.NoError(t, img.WriteSidecar(path))
+	require.NoError(t, opened.ReadMetadata())
+
+	datum, err = opened.GetXmpData().FindKey("Xmp.tiff.Make")
+	require.NoError(t, err)
+	require.NotNil(t, datum)
+	assert.Equal(t, "FakeMake", datum.String())
+
+	_, err = goexiv.OpenSidecar("testdata/pixel.jpg")
+	assert.Error(t, err)
+	assert.Error(t, img.MergeSidecar("testdata/does-not-exist.xmp"))
+}
+
+func TestSync(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+
+	xmpString := func(key string) string {
+		datum, err := img.GetXmpData().FindKey(key)
+		require.NoError(t, err)
+		require.NotNil(t, datum, key)
+		return datum.String()
 	}
 
-	wg.Wait()
-	runtime.GC()
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
-	t.Logf("Allocated bytes after test:  %+v\n", memStats.HeapAlloc)
+	// existing values are kept unless asked otherwise
+	require.NoError(t, img.SetXmpString("Xmp.tiff.Make", "OtherMake"))
+	require.NoError(t, img.SyncExifToXmp(goexiv.SyncOptions{}))
+	require.NoError(t, img.ReadMetadata())
+	assert.Equal(t, "OtherMake", xmpString("Xmp.tiff.Make"))
+	assert.Equal(t, "FakeModel", xmpString("Xmp.tiff.Model"))
+
+	require.NoError(t, img.SyncExifToXmp(goexiv.SyncOptions{Overwrite: true}))
+	require.NoError(t, img.ReadMetadata())
+	assert.Equal(t, "FakeMake", xmpString("Xmp.tiff.Make"))
+
+	require.NoError(t, img.SetIptcStrings("Iptc.Application2.Keywords", []string{"cat", "garden"}))
+	require.NoError(t, img.SyncIptcToXmp(goexiv.SyncOptions{Charset: "ISO-8859-1"}))
+	require.NoError(t, img.ReadMetadata())
+	subject, err := img.GetXmpData().GetArray("Xmp.dc.subject")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cat", "garden"}, subject)
+
+	require.NoError(t, img.SetXmpString("Xmp.tiff.Software", "goexiv"))
+	require.NoError(t, img.SetXmpArray("Xmp.dc.creator", goexiv.TypeXmpSeq, []string{"John Doe"}))
+	require.NoError(t, img.SyncXmpToExif(goexiv.SyncOptions{Overwrite: true}))
+	require.NoError(t, img.SyncXmpToIptc(goexiv.SyncOptions{}))
+	require.NoError(t, img.ReadMetadata())
+
+	datum, err := img.GetExifData().FindKey("Exif.Image.Software")
+	require.NoError(t, err)
+	require.NotNil(t, datum)
+	assert.Equal(t, "goexiv", datum.String())
+
+	byline, err := img.GetIptcData().GetAll("Iptc.Application2.Byline")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"John Doe"}, byline)
+
+	charset, err := img.GetIptcData().FindKey("Iptc.Envelope.CharacterSet")
+	require.NoError(t, err)
+	require.NotNil(t, charset)
+	assert.Equal(t, "\x1b%G", charset.String())
+}
+
+func TestCopyMetadata(t *testing.T) {
+	srcBytes, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	src, err := goexiv.OpenBytes(srcBytes)
+	require.NoError(t, err)
+	defer src.Close()
+	require.NoError(t, src.Set("Exif.Photo.PixelXDimension", "640"))
+	require.NoError(t, src.Set("Exif.Photo.PixelYDimension", "480"))
+	require.NoError(t, src.ReadMetadata())
+
+	dstBytes, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+
+	dst, err := goexiv.OpenBytes(dstBytes)
+	require.NoError(t, err)
+	defer dst.Close()
+	require.NoError(t, dst.ReadMetadata())
+
+	require.NoError(t, goexiv.CopyMetadata(dst, src, goexiv.CopyOptions{
+		Formats:       []goexiv.MetadataFormat{goexiv.EXIF, goexiv.XMP},
+		Exclude:       []string{"Exif.Image.Make", "Xmp.iptc.JobId"},
+		FixDimensions: true,
+	}))
+	require.NoError(t, dst.ReadMetadata())
+
+	exif := dst.GetExifData().AllTags()
+	assert.Equal(t, "FakeModel", exif["Exif.Image.Model"])
+	assert.NotContains(t, exif, "Exif.Image.Make")
+	assert.Equal(t, "1", exif["Exif.Photo.PixelXDimension"])
+	assert.Equal(t, "1", exif["Exif.Photo.PixelYDimension"])
+	assert.Empty(t, dst.GetIptcData().AllTags())
+	assert.Equal(t, map[string]string{
+		"Xmp.iptc.CopyrightNotice": "this is the copy, right?",
+		"Xmp.iptc.CreditLine":      "John Doe",
+	}, dst.GetXmpData().AllTags())
+
+	// copied keys replace the existing ones, other keys are kept
+	require.NoError(t, dst.SetIptcString("Iptc.Application2.CountryName", "Überwald"))
+	require.NoError(t, dst.SetIptcString("Iptc.Application2.City", "Bad Schüschein"))
+	require.NoError(t, goexiv.CopyMetadata(dst, src, goexiv.CopyOptions{
+		Include: []string{"Iptc.Application2.CountryName", "Xmp.iptc."},
+	}))
+	require.NoError(t, dst.ReadMetadata())
+
+	assert.Equal(t, map[string]string{
+		"Iptc.Application2.CountryName": "Lancre",
+		"Iptc.Application2.City":        "Bad Schüschein",
+	}, dst.GetIptcData().AllTags())
+	assert.Contains(t, dst.GetXmpData().AllTags(), "Xmp.iptc.JobId")
+
+	assert.Error(t, goexiv.CopyMetadata(dst, src, goexiv.CopyOptions{Formats: []goexiv.MetadataFormat{999}}))
+
+	require.NoError(t, src.Close())
+	assert.Equal(t, goexiv.ErrImageClosed, goexiv.CopyMetadata(dst, src, goexiv.CopyOptions{}))
+}
+
+func TestICCProfile(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+
+	info, err := img.ICCProfileInfo()
+	require.NoError(t, err)
+	assert.Nil(t, info)
+
+	profile := makeICCProfile("Fake sRGB")
+	require.NoError(t, img.SetICCProfile(profile))
+	require.NoError(t, img.ReadMetadata())
+	assert.Equal(t, profile, img.ICCProfile())
+
+	info, err = img.ICCProfileInfo()
+	require.NoError(t, err)
+	assert.Equal(t, &goexiv.ICCProfileInfo{
+		Version:     "2.1.0",
+		DeviceClass: "mntr",
+		ColorSpace:  "RGB",
+		Description: "Fake sRGB",
+	}, info)
+
+	require.NoError(t, img.ClearICCProfile())
+	require.NoError(t, img.ReadMetadata())
+	assert.Nil(t, img.ICCProfile())
+
+	// invalid profiles are rejected
+	assert.Error(t, img.SetICCProfile([]byte("not a profile")))
+	assert.Error(t, img.SetICCProfile(profile[:len(profile)-1]))
+	noSignature := append([]byte{}, profile...)
+	copy(noSignature[36:40], "xxxx")
+	assert.Error(t, img.SetICCProfile(noSignature))
+}
+
+func TestExifThumbnail(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+
+	mime, data, err := img.ExifThumbnail()
+	require.NoError(t, err)
+	assert.Empty(t, mime)
+	assert.Nil(t, data)
+
+	thumbnail, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+	require.NoError(t, img.SetExifThumbnail(thumbnail))
+	require.NoError(t, img.ReadMetadata())
+
+	mime, data, err = img.ExifThumbnail()
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", mime)
+	assert.Equal(t, thumbnail, data)
+
+	require.NoError(t, img.RemoveExifThumbnail())
+	require.NoError(t, img.ReadMetadata())
+
+	_, data, err = img.ExifThumbnail()
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	exif := img.GetExifData().AllTags()
+	assert.Equal(t, "FakeMake", exif["Exif.Image.Make"])
+	assert.NotContains(t, exif, "Exif.Thumbnail.JPEGInterchangeFormat")
+
+	assert.Error(t, img.SetExifThumbnail([]byte("not a jpeg")))
+}
+
+func TestPreviews(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+	assert.Empty(t, img.Previews())
+
+	// the EXIF thumbnail is a preview
+	thumbnail, err := os.ReadFile("testdata/stripped_pixel.jpg")
+	require.NoError(t, err)
+	require.NoError(t, img.SetExifThumbnail(thumbnail))
+	require.NoError(t, img.ReadMetadata())
+
+	previews := img.Previews()
+	require.Len(t, previews, 1)
+	assert.Equal(t, "image/jpeg", previews[0].MimeType)
+	assert.Equal(t, ".jpg", previews[0].Extension)
+	assert.Equal(t, 1, previews[0].Width)
+	assert.Equal(t, 1, previews[0].Height)
+	assert.Equal(t, int64(len(thumbnail)), previews[0].Size)
+
+	data, err := img.Preview(previews[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, thumbnail, data)
+
+	_, err = img.Preview(previews[0].ID + 1000)
+	assert.Error(t, err)
+
+	previews, err = img.PreviewsErr()
+	require.NoError(t, err)
+	assert.Len(t, previews, 1)
+
+	require.NoError(t, img.Close())
+	assert.Nil(t, img.Previews())
+	_, err = img.PreviewsErr()
+	assert.Equal(t, goexiv.ErrImageClosed, err)
+}
+
+func TestComment(t *testing.T) {
+	bytes, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(bytes)
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+	assert.Empty(t, img.Comment())
+
+	require.NoError(t, img.SetComment("uid=1234"))
+	require.NoError(t, img.ReadMetadata())
+	assert.Equal(t, "uid=1234", img.Comment())
+
+	require.NoError(t, img.ClearComment())
+	require.NoError(t, img.ReadMetadata())
+	assert.Empty(t, img.Comment())
+
+	// StripMetadata removes the comment too
+	require.NoError(t, img.SetComment("uid=1234"))
+	require.NoError(t, img.StripMetadata(nil))
+	require.NoError(t, img.ReadMetadata())
+	assert.Empty(t, img.Comment())
+}
+
+func TestImageType(t *testing.T) {
+	jpeg, err := goexiv.Open("testdata/pixel.jpg")
+	require.NoError(t, err)
+	defer jpeg.Close()
+
+	assert.Equal(t, goexiv.ImageTypeJPEG, jpeg.Type())
+	assert.Equal(t, "image/jpeg", jpeg.MimeType())
+	assert.Equal(t, goexiv.AccessReadWrite, jpeg.Supports(goexiv.IPTC))
+
+	webp, err := goexiv.Open("testdata/pixel.webp")
+	require.NoError(t, err)
+	defer webp.Close()
+
+	assert.Equal(t, goexiv.ImageTypeWebP, webp.Type())
+	assert.Equal(t, "webp", webp.Type().String())
+	assert.Equal(t, "image/webp", webp.MimeType())
+	assert.True(t, webp.Supports(goexiv.XMP).CanWrite())
+	assert.Equal(t, goexiv.AccessNone, webp.Supports(goexiv.IPTC))
+
+	bytes, err := os.ReadFile("testdata/pixel.webp")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.ImageTypeWebP, goexiv.DetectType(bytes))
+	assert.Equal(t, goexiv.ImageTypeUnknown, goexiv.DetectType([]byte("not an image")))
+	assert.Equal(t, goexiv.ImageTypeUnknown, goexiv.DetectType(nil))
+}
+
+func TestOpenReader(t *testing.T) {
+	f, err := os.Open("testdata/pixel.jpg")
+	require.NoError(t, err)
+	defer f.Close()
+
+	info, err := f.Stat()
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenReaderAt(f, info.Size())
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+
+	datum, err := img.GetExifData().FindKey("Exif.Image.Make")
+	require.NoError(t, err)
+	require.NotNil(t, datum)
+	assert.Equal(t, "FakeMake", datum.String())
+	assert.Len(t, img.GetBytes(), int(info.Size()))
+
+	// written metadata is kept in memory, the file is left untouched
+	require.NoError(t, img.SetExifString("Exif.Image.Make", "OtherMake"))
+	require.NoError(t, img.ReadMetadata())
+	assert.Equal(t, "OtherMake", img.GetExifData().AllTags()["Exif.Image.Make"])
+
+	original, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+	assert.NotEqual(t, original, img.GetBytes())
+
+	for _, r := range []io.Reader{
+		io.NewSectionReader(f, 0, info.Size()),
+		f,
+		struct {
+			io.ReaderAt
+			io.ReadSeeker
+		}{f, f},
+		io.MultiReader(io.NewSectionReader(f, 0, info.Size())),
+	} {
+		img, err := goexiv.OpenReader(r)
+		require.NoError(t, err)
+		require.NoError(t, img.ReadMetadata())
+		assert.Equal(t, "FakeMake", img.GetExifData().AllTags()["Exif.Image.Make"])
+		require.NoError(t, img.Close())
+	}
+
+	_, err = goexiv.OpenReader(io.MultiReader())
+	assert.Error(t, err)
+	_, err = goexiv.OpenReaderAt(io.NewSectionReader(f, 0, 10), 10)
+	assert.Error(t, err)
+}
+
+// failingReaderAt fails every read while fail is true.
+type failingReaderAt struct {
+	r    io.ReaderAt
+	fail bool
+}
+
+func (r *failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if r.fail {
+		return 0, errors.New("read failed")
+	}
+	return r.r.ReadAt(p, off)
+}
+
+func TestOpenReaderAt_ReadError(t *testing.T) {
+	data, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	r := &failingReaderAt{r: bytes.NewReader(data)}
+	img, err := goexiv.OpenReaderAt(r, int64(len(data)))
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+
+	r.fail = true
+	assert.Nil(t, img.GetBytes())
+	_, err = img.WriteTo(io.Discard)
+	assert.Error(t, err)
+
+	// a failed read doesn't fail the next ones
+	r.fail = false
+	var buf bytes.Buffer
+	_, err = img.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, data, buf.Bytes())
+	assert.Equal(t, data, img.GetBytes())
+}
+
+func TestWriteToAndSaveAs(t *testing.T) {
+	data, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(data)
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.SetExifString("Exif.Image.Make", "OtherMake"))
+
+	var buf bytes.Buffer
+	n, err := img.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Equal(t, img.GetBytes(), buf.Bytes())
+
+	path := filepath.Join(t.TempDir(), "pixel.jpg")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	require.NoError(t, img.SaveAs(path))
+
+	saved, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, buf.Bytes(), saved)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the temporary file must be renamed")
+
+	assert.Error(t, img.SaveAs(filepath.Join(path, "not-a-dir", "pixel.jpg")))
+
+	require.NoError(t, img.Close())
+	_, err = img.WriteTo(&buf)
+	assert.Equal(t, goexiv.ErrImageClosed, err)
+}
+
+func TestGPS(t *testing.T) {
+	data, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(data)
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+
+	info, err := img.GPS()
+	require.NoError(t, err)
+	assert.Nil(t, info)
+
+	altitude, direction := -12.5, 370.0
+	timestamp := time.Date(2023, 6, 1, 14, 30, 15, 250e6, time.FixedZone("CEST", 2*60*60))
+	require.NoError(t, img.SetGPS(goexiv.GPSInfo{
+		Latitude:  52.520008,
+		Longitude: -13.404954,
+		Altitude:  &altitude,
+		Timestamp: timestamp,
+		Direction: &direction,
+	}))
+	require.NoError(t, img.ReadMetadata())
+
+	exif := img.GetExifData().AllTags()
+	assert.Equal(t, "N", exif["Exif.GPSInfo.GPSLatitudeRef"])
+	assert.Equal(t, "52/1 31/1 120288/10000", exif["Exif.GPSInfo.GPSLatitude"])
+	assert.Equal(t, "W", exif["Exif.GPSInfo.GPSLongitudeRef"])
+	assert.Equal(t, "2 2 0 0", exif["Exif.GPSInfo.GPSVersionID"])
+	assert.Equal(t, "12500/1000", exif["Exif.GPSInfo.GPSAltitude"])
+	assert.Equal(t, "2023:06:01", exif["Exif.GPSInfo.GPSDateStamp"])
+
+	xmp := img.GetXmpData().AllTags()
+	assert.Equal(t, "52,31.200480N", xmp["Xmp.exif.GPSLatitude"])
+	assert.Equal(t, "13,24.297240W", xmp["Xmp.exif.GPSLongitude"])
+	assert.Equal(t, "1", xmp["Xmp.exif.GPSAltitudeRef"])
+
+	info, err = img.GPS()
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.InDelta(t, 52.520008, info.Latitude, 1e-7)
+	assert.InDelta(t, -13.404954, info.Longitude, 1e-7)
+	require.NotNil(t, info.Altitude)
+	assert.Equal(t, -12.5, *info.Altitude)
+	require.NotNil(t, info.Direction)
+	assert.Equal(t, 10.0, *info.Direction)
+	assert.True(t, timestamp.Equal(info.Timestamp), "got %s", info.Timestamp)
+
+	// malformed optional tags, as written by some phones, are ignored
+	e, err := img.Begin()
+	require.NoError(t, err)
+	require.NoError(t, e.SetExifValue("Exif.GPSInfo.GPSTimeStamp", goexiv.NewValue(goexiv.TypeUnsignedRational, "0/0 0/0 0/0")))
+	require.NoError(t, e.SetExifValue("Exif.GPSInfo.GPSAltitude", goexiv.NewValue(goexiv.TypeUnsignedRational, "0/0")))
+	require.NoError(t, e.Commit())
+	require.NoError(t, img.ReadMetadata())
+
+	info, err = img.GPS()
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.InDelta(t, 52.520008, info.Latitude, 1e-7)
+	assert.Nil(t, info.Altitude)
+	assert.True(t, info.Timestamp.IsZero())
+	require.NotNil(t, info.Direction)
+
+	// the XMP properties are used when the EXIF tags are missing
+	require.NoError(t, img.RemoveGPS())
+	require.NoError(t, img.SetXmpString("Xmp.exif.GPSLatitude", "52,31,12S"))
+	require.NoError(t, img.SetXmpString("Xmp.exif.GPSLongitude", "13,24.3E"))
+	require.NoError(t, img.ReadMetadata())
+
+	info, err = img.GPS()
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.InDelta(t, -52.52, info.Latitude, 1e-9)
+	assert.InDelta(t, 13.405, info.Longitude, 1e-9)
+	assert.Nil(t, info.Altitude)
+
+	require.NoError(t, img.RemoveGPS())
+	require.NoError(t, img.ReadMetadata())
+
+	info, err = img.GPS()
+	require.NoError(t, err)
+	assert.Nil(t, info)
+	assert.NotContains(t, img.GetExifData().AllTags(), "Exif.GPSInfo.GPSLatitude")
+	assert.Equal(t, "FakeMake", img.GetExifData().AllTags()["Exif.Image.Make"])
+
+	assert.Error(t, img.SetGPS(goexiv.GPSInfo{Latitude: 91}))
+}
+
+func TestCaptureTime(t *testing.T) {
+	data, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(data)
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+
+	iptcTime := time.Date(2012, 10, 13, 12, 49, 32, 0, time.FixedZone("", 60*60))
+
+	captured, source, err := img.CaptureTime()
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.CaptureTimeIptc, source)
+	assert.True(t, iptcTime.Equal(captured), "got %s", captured)
+
+	// DateTimeOriginal has no offset, it is taken from the IPTC time
+	require.NoError(t, img.SetExifString("Exif.Photo.DateTimeOriginal", "2012:10:13 12:49:32"))
+	require.NoError(t, img.ReadMetadata())
+
+	captured, source, err = img.CaptureTime()
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.CaptureTimeExif, source)
+	assert.True(t, iptcTime.Equal(captured), "got %s", captured)
+
+	taken := time.Date(2023, 6, 1, 14, 30, 15, 250e6, time.FixedZone("CEST", 2*60*60))
+	require.NoError(t, img.SetCaptureTime(taken))
+	require.NoError(t, img.ReadMetadata())
+
+	exif := img.GetExifData().AllTags()
+	assert.Equal(t, "2023:06:01 14:30:15", exif["Exif.Photo.DateTimeOriginal"])
+	assert.Equal(t, "25", exif["Exif.Photo.SubSecTimeOriginal"])
+	assert.Equal(t, "+02:00", exif["Exif.Photo.OffsetTimeOriginal"])
+	assert.Equal(t, "2023-06-01T14:30:15.25+02:00", img.GetXmpData().AllTags()["Xmp.photoshop.DateCreated"])
+	iptc := img.GetIptcData().AllTags()
+	assert.Equal(t, "2023-06-01", iptc["Iptc.Application2.DateCreated"])
+	assert.Equal(t, "14:30:15+02:00", iptc["Iptc.Application2.TimeCreated"])
+
+	captured, source, err = img.CaptureTime()
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.CaptureTimeExif, source)
+	assert.True(t, taken.Equal(captured), "got %s", captured)
+	_, offset := captured.Zone()
+	assert.Equal(t, 2*60*60, offset)
+
+	// a malformed value is skipped
+	require.NoError(t, img.SetExifString("Exif.Photo.DateTimeOriginal", "yesterday"))
+	require.NoError(t, img.ReadMetadata())
+
+	captured, source, err = img.CaptureTime()
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.CaptureTimeXmpPhotoshop, source)
+	assert.True(t, taken.Equal(captured), "got %s", captured)
+}
+
+func TestShiftTimes(t *testing.T) {
+	data, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(data)
+	require.NoError(t, err)
+	defer img.Close()
+
+	e, err := img.Begin()
+	require.NoError(t, err)
+	require.NoError(t, e.SetExifString("Exif.Image.DateTime", "garbage"))
+	require.NoError(t, e.SetExifString("Exif.Photo.DateTimeOriginal", "2013:12:08 21:06:10"))
+	require.NoError(t, e.SetExifString("Exif.Photo.SubSecTimeOriginal", "5"))
+	require.NoError(t, e.SetExifString("Exif.Photo.OffsetTimeOriginal", "+01:00"))
+	require.NoError(t, e.SetXmpString("Xmp.xmp.CreateDate", "2013-12-08T21:06:10.5+01:00"))
+	require.NoError(t, e.SetXmpString("Xmp.photoshop.DateCreated", "2013-12-08"))
+	require.NoError(t, e.Commit())
+	require.NoError(t, img.ReadMetadata())
+
+	skipped, err := img.ShiftTimes(25*time.Hour+30*time.Minute, goexiv.ShiftOptions{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Exif.Image.DateTime", "Xmp.photoshop.DateCreated"}, skipped)
+	require.NoError(t, img.ReadMetadata())
+
+	exif := img.GetExifData().AllTags()
+	assert.Equal(t, "garbage", exif["Exif.Image.DateTime"])
+	assert.Equal(t, "2013:12:09 22:36:10", exif["Exif.Photo.DateTimeOriginal"])
+	assert.Equal(t, "5", exif["Exif.Photo.SubSecTimeOriginal"])
+	assert.Equal(t, "+01:00", exif["Exif.Photo.OffsetTimeOriginal"])
+	assert.Equal(t, "2013:12:09 22:36:10", exif["Exif.Photo.DateTimeDigitized"])
+	iptc := img.GetIptcData().AllTags()
+	assert.Equal(t, "2012-10-14", iptc["Iptc.Application2.DateCreated"])
+	assert.Equal(t, "14:19:32+01:00", iptc["Iptc.Application2.TimeCreated"])
+	xmp := img.GetXmpData().AllTags()
+	assert.Equal(t, "2013-12-09T22:36:10.5+01:00", xmp["Xmp.xmp.CreateDate"])
+	assert.Equal(t, "2013-12-08", xmp["Xmp.photoshop.DateCreated"])
+
+	// the camera was an hour ahead of UTC instead of on UTC
+	_, err = img.ShiftTimes(-time.Hour, goexiv.ShiftOptions{UpdateOffsets: true})
+	require.NoError(t, err)
+	require.NoError(t, img.ReadMetadata())
+
+	exif = img.GetExifData().AllTags()
+	assert.Equal(t, "2013:12:09 21:36:10", exif["Exif.Photo.DateTimeOriginal"])
+	assert.Equal(t, "+00:00", exif["Exif.Photo.OffsetTimeOriginal"])
+	assert.Equal(t, "13:19:32+00:00", img.GetIptcData().AllTags()["Iptc.Application2.TimeCreated"])
+	assert.Equal(t, "2013-12-09T21:36:10.5Z", img.GetXmpData().AllTags()["Xmp.xmp.CreateDate"])
+
+	_, err = img.ShiftTimes(time.Second, goexiv.ShiftOptions{UpdateOffsets: true})
+	assert.Error(t, err)
+}
+
+func TestOrientation(t *testing.T) {
+	data, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(data)
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+
+	o, err := img.Orientation()
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.OrientationNormal, o)
+
+	e, err := img.Begin()
+	require.NoError(t, err)
+	require.NoError(t, e.SetOrientation(goexiv.OrientationRotate90))
+	require.NoError(t, e.SetExifValue("Exif.Photo.PixelXDimension", goexiv.NewUnsignedLongValue(3)))
+	require.NoError(t, e.SetExifValue("Exif.Photo.PixelYDimension", goexiv.NewUnsignedShortValue(2)))
+	require.NoError(t, e.SetXmpString("Xmp.exif.PixelXDimension", "3"))
+	require.NoError(t, e.Commit())
+	require.NoError(t, img.ReadMetadata())
+
+	assert.Equal(t, "6", img.GetExifData().AllTags()["Exif.Image.Orientation"])
+	assert.Equal(t, "6", img.GetXmpData().AllTags()["Xmp.tiff.Orientation"])
+
+	o, err = img.Orientation()
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.OrientationRotate90, o)
+	assert.Equal(t, 90, o.Rotation())
+	assert.False(t, o.Mirrored())
+	assert.True(t, o.SwapsDimensions())
+
+	require.NoError(t, img.ResetOrientation())
+	require.NoError(t, img.ReadMetadata())
+
+	o, err = img.Orientation()
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.OrientationNormal, o)
+
+	exifData := img.GetExifData()
+	assert.Equal(t, "2", exifData.AllTags()["Exif.Photo.PixelXDimension"])
+	assert.Equal(t, "3", exifData.AllTags()["Exif.Photo.PixelYDimension"])
+	datum, err := exifData.FindKey("Exif.Photo.PixelYDimension")
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.TypeUnsignedLong, datum.TypeID())
+
+	xmp := img.GetXmpData().AllTags()
+	assert.NotContains(t, xmp, "Xmp.exif.PixelXDimension")
+	assert.Equal(t, "3", xmp["Xmp.exif.PixelYDimension"])
+	assert.Equal(t, "1", xmp["Xmp.tiff.Orientation"])
+
+	// the XMP orientation is used when the EXIF one is missing
+	require.NoError(t, img.ExifStripKey("Exif.Image.Orientation"))
+	require.NoError(t, img.SetXmpString("Xmp.tiff.Orientation", "8"))
+	require.NoError(t, img.ReadMetadata())
+
+	o, err = img.Orientation()
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.OrientationRotate270, o)
+
+	// 0 means unknown, the image is then upright
+	require.NoError(t, img.SetExifValue("Exif.Image.Orientation", goexiv.NewUnsignedShortValue(0)))
+	require.NoError(t, img.ReadMetadata())
+
+	o, err = img.Orientation()
+	require.NoError(t, err)
+	assert.Equal(t, goexiv.OrientationNormal, o)
+	require.NoError(t, img.ResetOrientation())
+
+	require.NoError(t, img.SetExifValue("Exif.Image.Orientation", goexiv.NewUnsignedShortValue(9)))
+	require.NoError(t, img.ReadMetadata())
+	_, err = img.Orientation()
+	assert.Error(t, err)
+
+	assert.Error(t, img.SetOrientation(9))
+	assert.Error(t, img.SetOrientation(0))
+}
+
+func TestShootingInfo(t *testing.T) {
+	data, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+
+	img, err := goexiv.OpenBytes(data)
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+
+	info, err := img.ShootingInfo()
+	require.NoError(t, err)
+	assert.Equal(t, &goexiv.ShootingInfo{Make: "FakeMake", Model: "FakeModel"}, info)
+
+	e, err := img.Begin()
+	require.NoError(t, err)
+	require.NoError(t, e.SetExifString("Exif.Image.CameraSerialNumber", "1234"))
+	require.NoError(t, e.SetExifString("Exif.Photo.LensModel", "FakeLens 50mm"))
+	require.NoError(t, e.SetExifString("Exif.Photo.LensSerialNumber", "5678"))
+	require.NoError(t, e.SetExifValue("Exif.Photo.FocalLength", goexiv.NewUnsignedRationalValue(goexiv.Rational{Numerator: 50, Denominator: 1})))
+	require.NoError(t, e.SetExifValue("Exif.Photo.FNumber", goexiv.NewUnsignedRationalValue(goexiv.Rational{Numerator: 28, Denominator: 10})))
+	require.NoError(t, e.SetExifValue("Exif.Photo.ExposureTime", goexiv.NewUnsignedRationalValue(goexiv.Rational{Numerator: 1, Denominator: 250})))
+	require.NoError(t, e.SetExifValue("Exif.Photo.ISOSpeedRatings", goexiv.NewUnsignedShortValue(400)))
+	require.NoError(t, e.SetExifValue("Exif.Photo.Flash", goexiv.NewUnsignedShortValue(1)))
+	require.NoError(t, e.SetExifValue("Exif.Photo.WhiteBalance", goexiv.NewUnsignedShortValue(0)))
+	require.NoError(t, e.SetExifValue("Exif.Photo.ExposureBiasValue", goexiv.NewSignedRationalValue(goexiv.Rational{Numerator: -1, Denominator: 3})))
+	require.NoError(t, e.Commit())
+	require.NoError(t, img.ReadMetadata())
+
+	info, err = img.ShootingInfo()
+	require.NoError(t, err)
+	assert.Equal(t, &goexiv.ShootingInfo{
+		Make:             "FakeMake",
+		Model:            "FakeModel",
+		SerialNumber:     "1234",
+		LensModel:        "FakeLens 50mm",
+		LensSerialNumber: "5678",
+		FocalLength:      50,
+		FNumber:          2.8,
+		ExposureTime:     goexiv.Rational{Numerator: 1, Denominator: 250},
+		ISO:              400,
+		Flash:            "Fired",
+		WhiteBalance:     "Auto",
+		ExposureBias:     goexiv.Rational{Numerator: -1, Denominator: 3},
+	}, info)
 }
 
 // TestStripKey when metadata format is invalid
@@ -688,6 +2244,30 @@ func TestStripMetadata(t *testing.T) {
 	}, xmpData.AllTags())
 }
 
+func TestStripMetadata_WriteFailed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pixel.jpg")
+	data, err := os.ReadFile("testdata/pixel.jpg")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	img, err := goexiv.Open(path)
+	require.NoError(t, err)
+	defer img.Close()
+	require.NoError(t, img.ReadMetadata())
+	exifTags := img.GetExifData().AllTags()
+	iptcTags := img.GetIptcData().AllTags()
+	xmpTags := img.GetXmpData().AllTags()
+
+	// the write fails as the file is gone
+	require.NoError(t, os.Remove(path))
+	assert.Error(t, img.StripMetadata(nil))
+
+	// nothing is half stripped
+	assert.Equal(t, exifTags, img.GetExifData().AllTags())
+	assert.Equal(t, iptcTags, img.GetIptcData().AllTags())
+	assert.Equal(t, xmpTags, img.GetXmpData().AllTags())
+}
+
 func BenchmarkImage_GetBytes_KeepAlive(b *testing.B) {
 	bytes, err := os.ReadFile("testdata/stripped_pixel.jpg")
 	require.NoError(b, err)
@@ -769,3 +2349,25 @@ func initializeImage(path string, t *testing.T) {
 	img.SetXmpString("Xmp.iptc.CopyrightNotice", "this is the copy, right?")
 	img.SetXmpString("Xmp.iptc.JobId", "12345")
 }
+
+// makeICCProfile returns a minimal ICC v2 display profile with a
+// description tag.
+func makeICCProfile(description string) []byte {
+	desc := make([]byte, 12+len(description)+1)
+	copy(desc, "desc")
+	binary.BigEndian.PutUint32(desc[8:], uint32(len(description)+1))
+	copy(desc[12:], description)
+
+	profile := make([]byte, 128+4+12, 128+4+12+len(desc))
+	profile[8], profile[9] = 2, 0x10
+	copy(profile[12:], "mntrRGB XYZ ")
+	copy(profile[36:], "acsp")
+	binary.BigEndian.PutUint32(profile[128:], 1)
+	copy(profile[132:], "desc")
+	binary.BigEndian.PutUint32(profile[136:], uint32(len(profile)))
+	binary.BigEndian.PutUint32(profile[140:], uint32(len(desc)))
+	profile = append(profile, desc...)
+	binary.BigEndian.PutUint32(profile[0:], uint32(len(profile)))
+
+	return profile
+}
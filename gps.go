@@ -0,0 +1,441 @@
+package goexiv
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const gpsDateLayout = "2006:01:02"
+
+// GPSInfo is the location an image was taken at, in WGS-84 coordinates.
+type GPSInfo struct {
+	// Latitude in decimal degrees, negative south of the equator.
+	Latitude float64
+	// Longitude in decimal degrees, negative west of Greenwich.
+	Longitude float64
+	// Altitude in meters, negative below sea level. Nil if unknown.
+	Altitude *float64
+	// Timestamp is the GPS time, in UTC. Zero if unknown.
+	Timestamp time.Time
+	// Direction is the direction the image was taken in, in degrees
+	// from true north. Nil if unknown.
+	Direction *float64
+}
+
+// GPS returns the location of the image, read from the EXIF GPS tags or,
+// if they are missing, from the Xmp.exif.GPS* properties. It returns nil
+// if the image has no location.
+func (i *Image) GPS() (*GPSInfo, error) {
+	if i.closed() {
+		return nil, ErrImageClosed
+	}
+
+	exifData := i.GetExifData()
+	defer exifData.Close()
+
+	info, err := exifGPS(exifData)
+	if err != nil || info != nil {
+		return info, err
+	}
+
+	xmpData := i.GetXmpData()
+	defer xmpData.Close()
+
+	return xmpGPS(xmpData)
+}
+
+// SetGPS replaces the location of the image, in both the EXIF GPS tags and
+// the Xmp.exif.GPS* properties.
+func (i *Image) SetGPS(info GPSInfo) error {
+	return i.edit(func(e *Editor) error {
+		return e.SetGPS(info)
+	})
+}
+
+// RemoveGPS removes the location of the image, i.e. all the EXIF GPS tags
+// and the Xmp.exif.GPS* properties.
+func (i *Image) RemoveGPS() error {
+	return i.edit(func(e *Editor) error {
+		return e.RemoveGPS()
+	})
+}
+
+// SetGPS replaces the location of the image, in both the EXIF GPS tags and
+// the Xmp.exif.GPS* properties.
+func (e *Editor) SetGPS(info GPSInfo) error {
+	if math.IsNaN(info.Latitude) || math.Abs(info.Latitude) > 90 {
+		return fmt.Errorf("latitude %v out of range", info.Latitude)
+	}
+	if math.IsNaN(info.Longitude) || math.Abs(info.Longitude) > 180 {
+		return fmt.Errorf("longitude %v out of range", info.Longitude)
+	}
+	if err := e.RemoveGPS(); err != nil {
+		return err
+	}
+
+	latRef, lonRef := "N", "E"
+	if info.Latitude < 0 {
+		latRef = "S"
+	}
+	if info.Longitude < 0 {
+		lonRef = "W"
+	}
+
+	exif := []struct {
+		key   string
+		value Value
+	}{
+		{"Exif.GPSInfo.GPSVersionID", NewUnsignedByteValue([]byte{2, 2, 0, 0})},
+		{"Exif.GPSInfo.GPSLatitudeRef", NewAsciiValue(latRef)},
+		{"Exif.GPSInfo.GPSLatitude", NewUnsignedRationalValue(gpsCoordinate(info.Latitude)...)},
+		{"Exif.GPSInfo.GPSLongitudeRef", NewAsciiValue(lonRef)},
+		{"Exif.GPSInfo.GPSLongitude", NewUnsignedRationalValue(gpsCoordinate(info.Longitude)...)},
+		{"Exif.GPSInfo.GPSMapDatum", NewAsciiValue("WGS-84")},
+	}
+	xmp := [][2]string{
+		{"Xmp.exif.GPSVersionID", "2.2.0.0"},
+		{"Xmp.exif.GPSLatitude", xmpGPSCoordinate(info.Latitude, latRef)},
+		{"Xmp.exif.GPSLongitude", xmpGPSCoordinate(info.Longitude, lonRef)},
+		{"Xmp.exif.GPSMapDatum", "WGS-84"},
+	}
+
+	if info.Altitude != nil {
+		var ref byte
+		if *info.Altitude < 0 {
+			ref = 1
+		}
+		altitude := Rational{int64(math.Round(math.Abs(*info.Altitude) * 1000)), 1000}
+
+		exif = append(exif, []struct {
+			key   string
+			value Value
+		}{
+			{"Exif.GPSInfo.GPSAltitudeRef", NewUnsignedByteValue([]byte{ref})},
+			{"Exif.GPSInfo.GPSAltitude", NewUnsignedRationalValue(altitude)},
+		}...)
+		xmp = append(xmp,
+			[2]string{"Xmp.exif.GPSAltitudeRef", strconv.Itoa(int(ref))},
+			[2]string{"Xmp.exif.GPSAltitude", altitude.String()},
+		)
+	}
+
+	if !info.Timestamp.IsZero() {
+		t := info.Timestamp.UTC()
+		clock, date := exifGPSTimeStamp(t)
+
+		exif = append(exif, []struct {
+			key   string
+			value Value
+		}{
+			{"Exif.GPSInfo.GPSTimeStamp", clock},
+			{"Exif.GPSInfo.GPSDateStamp", date},
+		}...)
+		xmp = append(xmp, [2]string{"Xmp.exif.GPSTimeStamp", t.Format(time.RFC3339Nano)})
+	}
+
+	if info.Direction != nil {
+		direction := math.Mod(*info.Direction, 360)
+		if direction < 0 {
+			direction += 360
+		}
+		value := Rational{int64(math.Round(direction * 100)), 100}
+
+		exif = append(exif, []struct {
+			key   string
+			value Value
+		}{
+			{"Exif.GPSInfo.GPSImgDirectionRef", NewAsciiValue("T")},
+			{"Exif.GPSInfo.GPSImgDirection", NewUnsignedRationalValue(value)},
+		}...)
+		xmp = append(xmp,
+			[2]string{"Xmp.exif.GPSImgDirectionRef", "T"},
+			[2]string{"Xmp.exif.GPSImgDirection", value.String()},
+		)
+	}
+
+	for _, entry := range exif {
+		if err := e.SetExifValue(entry.key, entry.value); err != nil {
+			return err
+		}
+	}
+	for _, entry := range xmp {
+		if err := e.SetXmpString(entry[0], entry[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveGPS removes the location of the image, i.e. all the EXIF GPS tags
+// and the Xmp.exif.GPS* properties.
+func (e *Editor) RemoveGPS() error {
+	if err := e.stripPrefix(EXIF, "Exif.GPSInfo."); err != nil {
+		return err
+	}
+	if err := e.StripKey(EXIF, "Exif.Image.GPSTag"); err != nil {
+		return err
+	}
+	return e.stripPrefix(XMP, "Xmp.exif.GPS")
+}
+
+// gpsCoordinate returns the degrees, minutes and seconds of the absolute
+// value of a coordinate, with the seconds in 1/10000.
+func gpsCoordinate(v float64) []Rational {
+	const second = 10000
+
+	total := int64(math.Round(math.Abs(v) * 3600 * second))
+	degrees := total / (3600 * second)
+	total -= degrees * 3600 * second
+	minutes := total / (60 * second)
+	total -= minutes * 60 * second
+
+	return []Rational{{degrees, 1}, {minutes, 1}, {total, second}}
+}
+
+// xmpGPSCoordinate formats a coordinate as an XMP GPSCoordinate, e.g.
+// "52,31.205700N".
+func xmpGPSCoordinate(v float64, ref string) string {
+	const minute = 1000000
+
+	total := int64(math.Round(math.Abs(v) * 60 * minute))
+	degrees := total / (60 * minute)
+	total -= degrees * 60 * minute
+
+	return fmt.Sprintf("%d,%d.%06d%s", degrees, total/minute, total%minute, ref)
+}
+
+// exifGPSTimeStamp returns the values of the GPSTimeStamp and GPSDateStamp
+// tags of a UTC time, with the seconds in 1/1000.
+func exifGPSTimeStamp(t time.Time) (clock, date Value) {
+	seconds := Rational{int64(t.Second())*1000 + int64(t.Nanosecond()/1e6), 1000}
+	clock = NewUnsignedRationalValue(Rational{int64(t.Hour()), 1}, Rational{int64(t.Minute()), 1}, seconds)
+
+	return clock, NewAsciiValue(t.Format(gpsDateLayout))
+}
+
+// exifGPSTime reads the GPS time from the GPSDateStamp and GPSTimeStamp
+// tags. ok is false if either tag is missing or malformed, e.g. a time
+// stamp of "0/0 0/0 0/0".
+func exifGPSTime(d *ExifData) (t time.Time, ok bool) {
+	clock, err := exifRationals(d, "Exif.GPSInfo.GPSTimeStamp")
+	if err != nil || len(clock) != 3 {
+		return time.Time{}, false
+	}
+	date, err := exifString(d, "Exif.GPSInfo.GPSDateStamp")
+	if err != nil {
+		return time.Time{}, false
+	}
+	day, err := time.Parse(gpsDateLayout, date)
+	if err != nil {
+		return time.Time{}, false
+	}
+	offset := time.Duration(sexagesimal(clock) * float64(time.Hour))
+
+	return day.Add(offset.Round(time.Millisecond)), true
+}
+
+// exifGPS reads the location from the EXIF GPS tags. It returns nil if the
+// latitude or the longitude is missing.
+func exifGPS(d *ExifData) (*GPSInfo, error) {
+	lat, err := exifRationals(d, "Exif.GPSInfo.GPSLatitude")
+	if err != nil || lat == nil {
+		return nil, err
+	}
+	lon, err := exifRationals(d, "Exif.GPSInfo.GPSLongitude")
+	if err != nil || lon == nil {
+		return nil, err
+	}
+
+	info := &GPSInfo{
+		Latitude:  sexagesimal(lat),
+		Longitude: sexagesimal(lon),
+	}
+
+	latRef, err := exifString(d, "Exif.GPSInfo.GPSLatitudeRef")
+	if err != nil {
+		return nil, err
+	}
+	if latRef == "S" {
+		info.Latitude = -info.Latitude
+	}
+	lonRef, err := exifString(d, "Exif.GPSInfo.GPSLongitudeRef")
+	if err != nil {
+		return nil, err
+	}
+	if lonRef == "W" {
+		info.Longitude = -info.Longitude
+	}
+
+	// the other tags are optional, they are ignored if malformed
+	if altitude, err := exifRationals(d, "Exif.GPSInfo.GPSAltitude"); err == nil && len(altitude) > 0 {
+		info.Altitude = &altitude[0]
+		if ref, err := d.FindKey("Exif.GPSInfo.GPSAltitudeRef"); err == nil && ref != nil {
+			if v, err := ref.Int64(0); err == nil && v == 1 {
+				*info.Altitude = -*info.Altitude
+			}
+		}
+	}
+
+	if direction, err := exifRationals(d, "Exif.GPSInfo.GPSImgDirection"); err == nil && len(direction) > 0 {
+		info.Direction = &direction[0]
+	}
+
+	if timestamp, ok := exifGPSTime(d); ok {
+		info.Timestamp = timestamp
+	}
+
+	return info, nil
+}
+
+// xmpGPS reads the location from the Xmp.exif.GPS* properties. It returns
+// nil if the latitude or the longitude is missing.
+func xmpGPS(d *XmpData) (*GPSInfo, error) {
+	lat, err := xmpString(d, "Xmp.exif.GPSLatitude")
+	if err != nil || lat == "" {
+		return nil, err
+	}
+	lon, err := xmpString(d, "Xmp.exif.GPSLongitude")
+	if err != nil || lon == "" {
+		return nil, err
+	}
+
+	info := &GPSInfo{}
+	if info.Latitude, err = parseXmpGPSCoordinate(lat); err != nil {
+		return nil, err
+	}
+	if info.Longitude, err = parseXmpGPSCoordinate(lon); err != nil {
+		return nil, err
+	}
+
+	// the other properties are optional, they are ignored if malformed
+	if altitude, err := xmpString(d, "Xmp.exif.GPSAltitude"); err == nil && altitude != "" {
+		if v, err := parseRational(altitude); err == nil {
+			if ref, err := xmpString(d, "Xmp.exif.GPSAltitudeRef"); err == nil && ref == "1" {
+				v = -v
+			}
+			info.Altitude = &v
+		}
+	}
+
+	if direction, err := xmpString(d, "Xmp.exif.GPSImgDirection"); err == nil && direction != "" {
+		if v, err := parseRational(direction); err == nil {
+			info.Direction = &v
+		}
+	}
+
+	if timestamp, err := xmpString(d, "Xmp.exif.GPSTimeStamp"); err == nil {
+		if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+			info.Timestamp = t.UTC()
+		}
+	}
+
+	return info, nil
+}
+
+// sexagesimal returns the decimal value of degrees, minutes and seconds, or
+// hours, minutes and seconds.
+func sexagesimal(values []float64) float64 {
+	var v float64
+	for n, part := range values {
+		v += part / math.Pow(60, float64(n))
+	}
+	return v
+}
+
+// parseXmpGPSCoordinate parses an XMP GPSCoordinate, either "DDD,MM,SSk" or
+// "DDD,MM.mmk" where k is N, S, E or W.
+func parseXmpGPSCoordinate(s string) (float64, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid GPS coordinate %q", s)
+	}
+
+	parts := strings.Split(s[:len(s)-1], ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid GPS coordinate %q", s)
+	}
+
+	values := make([]float64, len(parts))
+	for n, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid GPS coordinate %q", s)
+		}
+		values[n] = v
+	}
+
+	v := sexagesimal(values)
+	switch s[len(s)-1] {
+	case 'N', 'E':
+		return v, nil
+	case 'S', 'W':
+		return -v, nil
+	}
+
+	return 0, fmt.Errorf("invalid GPS coordinate %q", s)
+}
+
+// parseRational parses a rational given as "numerator/denominator".
+func parseRational(s string) (float64, error) {
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		return strconv.ParseFloat(s, 64)
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rational %q", s)
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0, fmt.Errorf("invalid rational %q", s)
+	}
+
+	return n / d, nil
+}
+
+// exifRationals returns the values of a rational EXIF tag, or nil if the
+// tag is missing.
+func exifRationals(d *ExifData, key string) ([]float64, error) {
+	datum, err := d.FindKey(key)
+	if err != nil || datum == nil {
+		return nil, err
+	}
+
+	values := make([]float64, datum.Count())
+	for n := range values {
+		r, err := datum.Rational(n)
+		if err != nil {
+			return nil, err
+		}
+		if r.Denominator == 0 {
+			return nil, fmt.Errorf("%s has a zero denominator", key)
+		}
+		values[n] = r.Float64()
+	}
+
+	return values, nil
+}
+
+// exifString returns the value of an EXIF tag as a string, or an empty
+// string if the tag is missing.
+func exifString(d *ExifData, key string) (string, error) {
+	s, err := d.GetString(key)
+	if err == ErrMetadataKeyNotFound {
+		return "", nil
+	}
+	return s, err
+}
+
+// xmpString returns the value of an XMP property as a string, or an empty
+// string if the property is missing.
+func xmpString(d *XmpData, key string) (string, error) {
+	s, err := d.GetString(key)
+	if err == ErrMetadataKeyNotFound {
+		return "", nil
+	}
+	return s, err
+}
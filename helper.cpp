@@ -2,9 +2,16 @@
 
 #include <exiv2/image.hpp>
 #include <exiv2/error.hpp>
+#include <exiv2/xmpsidecar.hpp>
+#include <exiv2/futils.hpp>
+#include <exiv2/convert.hpp>
+#include <exiv2/preview.hpp>
+#include <exiv2/exiv2.hpp>
 
 #include <stdio.h>
 
+#include <set>
+
 #define DEFINE_STRUCT(name,wrapped_type,member_name) \
 struct _##name { \
 	_##name(wrapped_type member_name) \
@@ -21,8 +28,34 @@ void name##_free(type x) \
 DEFINE_STRUCT(Exiv2ImageFactory, Exiv2::ImageFactory*, factory);
 DEFINE_STRUCT(Exiv2Image, Exiv2::Image::AutoPtr, image);
 
+// The wrapped value is null when the datum has no value.
+DEFINE_STRUCT(Exiv2Value, const Exiv2::Value*, value);
+
+static const Exiv2::Value*
+valueOf(const Exiv2::Metadatum &datum)
+{
+	try {
+		return &datum.value();
+	} catch (Exiv2::Error &e) {
+		return 0;
+	}
+}
+
+// A datum owns a copy of the metadata entry, as a commit replaces the
+// entries of the image metadata, and a view on the value of the copy.
+#define DEFINE_DATUM_STRUCT(name,datum_type) \
+struct _##name { \
+	_##name(const datum_type &datum) \
+		: datum(datum), value(valueOf(this->datum)) {} \
+	const datum_type datum; \
+	Exiv2Value value; \
+private: \
+	_##name(const _##name&); \
+	_##name& operator=(const _##name&); \
+};
+
 DEFINE_STRUCT(Exiv2XmpData, const Exiv2::XmpData&, data);
-DEFINE_STRUCT(Exiv2XmpDatum, const Exiv2::Xmpdatum&, datum);
+DEFINE_DATUM_STRUCT(Exiv2XmpDatum, Exiv2::Xmpdatum);
 struct _Exiv2XmpDatumIterator {
 	_Exiv2XmpDatumIterator(Exiv2::XmpMetadata::const_iterator i, Exiv2::XmpMetadata::const_iterator e) : it(i), end(e) {}
 	Exiv2::XmpMetadata::const_iterator it;
@@ -33,7 +66,7 @@ struct _Exiv2XmpDatumIterator {
 };
 
 DEFINE_STRUCT(Exiv2ExifData, const Exiv2::ExifData&, data);
-DEFINE_STRUCT(Exiv2ExifDatum, const Exiv2::Exifdatum&, datum);
+DEFINE_DATUM_STRUCT(Exiv2ExifDatum, Exiv2::Exifdatum);
 struct _Exiv2ExifDatumIterator {
 	_Exiv2ExifDatumIterator(Exiv2::ExifMetadata::const_iterator i, Exiv2::ExifMetadata::const_iterator e) : it(i), end(e) {}
 	Exiv2::ExifMetadata::const_iterator it;
@@ -44,7 +77,7 @@ struct _Exiv2ExifDatumIterator {
 };
 
 DEFINE_STRUCT(Exiv2IptcData, const Exiv2::IptcData&, data);
-DEFINE_STRUCT(Exiv2IptcDatum, const Exiv2::Iptcdatum&, datum);
+DEFINE_DATUM_STRUCT(Exiv2IptcDatum, Exiv2::Iptcdatum);
 struct _Exiv2IptcDatumIterator {
 	_Exiv2IptcDatumIterator(Exiv2::IptcMetadata::const_iterator i, Exiv2::IptcMetadata::const_iterator e) : it(i), end(e) {}
 	Exiv2::IptcMetadata::const_iterator it;
@@ -54,6 +87,45 @@ struct _Exiv2IptcDatumIterator {
 	Exiv2IptcDatum* next();
 };
 
+// Exiv2Editor collects metadata changes on copies of the image metadata.
+// A family is copied the first time it is touched, and only the touched
+// families are handed back to the image on commit.
+struct _Exiv2Editor {
+	_Exiv2Editor(Exiv2::Image *image)
+		: image(image), exifDirty(false), iptcDirty(false), xmpDirty(false), xmpPacketSet(false), iccDirty(false), commentDirty(false) {}
+	Exiv2::Image *image;
+
+	Exiv2::ExifData exifData;
+	Exiv2::IptcData iptcData;
+	Exiv2::XmpData xmpData;
+	bool exifDirty;
+	bool iptcDirty;
+	bool xmpDirty;
+
+	// A raw packet is written as is, unless the XMP data is changed
+	// after it was set.
+	std::string xmpPacket;
+	bool xmpPacketSet;
+
+	// An empty profile clears the ICC profile of the image.
+	std::string iccProfile;
+	bool iccDirty;
+
+	// An empty comment clears the comment of the image.
+	std::string comment;
+	bool commentDirty;
+
+	Exiv2::ExifData& exif();
+	Exiv2::IptcData& iptc();
+	Exiv2::XmpData& xmp();
+
+	// The current data of a family, including uncommitted changes,
+	// without copying it.
+	const Exiv2::ExifData& readExif() const { return exifDirty ? exifData : image->exifData(); }
+	const Exiv2::IptcData& readIptc() const { return iptcDirty ? iptcData : image->iptcData(); }
+	const Exiv2::XmpData& readXmp() const { return xmpDirty ? xmpData : image->xmpData(); }
+};
+
 DEFINE_FREE_FUNCTION(exiv2_xmp_datum_iterator, Exiv2XmpDatumIterator*);
 DEFINE_FREE_FUNCTION(exiv2_iptc_datum_iterator, Exiv2IptcDatumIterator*);
 DEFINE_FREE_FUNCTION(exiv2_exif_datum_iterator, Exiv2ExifDatumIterator*);
@@ -109,6 +181,216 @@ exiv2_image_factory_open_bytes(const unsigned char *bytes, long size, Exiv2Error
 	return 0;
 }
 
+// GoReaderIo reads an image through an io.ReaderAt of the Go side. Once
+// metadata is written, the new image content is kept in memory.
+class GoReaderIo : public Exiv2::BasicIo {
+public:
+	GoReaderIo(uintptr_t handle, long size)
+		: handle_(handle), size_(size), pos_(0), error_(0), eof_(false), mapped_(0) {}
+	~GoReaderIo() { delete[] mapped_; }
+
+	int open()
+	{
+		if (mem_.get()) {
+			return mem_->open();
+		}
+		// a failed read is not reported by the next use of the image
+		error_ = 0;
+		return seek(0, beg);
+	}
+	int close() { return mem_.get() ? mem_->close() : 0; }
+
+	long write(const Exiv2::byte *data, long wcount) { return mem_.get() ? mem_->write(data, wcount) : 0; }
+	long write(Exiv2::BasicIo &src) { return mem_.get() ? mem_->write(src) : 0; }
+	int putb(Exiv2::byte data) { return mem_.get() ? mem_->putb(data) : EOF; }
+
+	Exiv2::DataBuf read(long rcount)
+	{
+		Exiv2::DataBuf buf(rcount);
+		long readCount = read(buf.pData_, buf.size_);
+		buf.size_ = readCount;
+		return buf;
+	}
+
+	long read(Exiv2::byte *buf, long rcount)
+	{
+		if (mem_.get()) {
+			return mem_->read(buf, rcount);
+		}
+		if (rcount <= 0 || pos_ >= size_) {
+			eof_ = rcount > 0;
+			return 0;
+		}
+		if (rcount > size_ - pos_) {
+			rcount = size_ - pos_;
+		}
+
+		const long n = goexivReadAt(handle_, buf, rcount, pos_);
+		if (n < 0) {
+			error_ = 1;
+			return 0;
+		}
+		pos_ += n;
+		eof_ = pos_ >= size_;
+		return n;
+	}
+
+	int getb()
+	{
+		Exiv2::byte b;
+		return read(&b, 1) == 1 ? b : EOF;
+	}
+
+	void transfer(Exiv2::BasicIo &src)
+	{
+		if (!mem_.get()) {
+			mem_.reset(new Exiv2::MemIo);
+		}
+		mem_->transfer(src);
+	}
+
+	int seek(long offset, Position pos)
+	{
+		if (mem_.get()) {
+			return mem_->seek(offset, pos);
+		}
+
+		long newPos = offset;
+		if (pos == cur) {
+			newPos = pos_ + offset;
+		} else if (pos == end) {
+			newPos = size_ + offset;
+		}
+		if (newPos < 0) {
+			return 1;
+		}
+		pos_ = newPos;
+		eof_ = pos_ > size_;
+		return 0;
+	}
+
+	Exiv2::byte* mmap(bool isWriteable = false)
+	{
+		if (mem_.get()) {
+			return mem_->mmap(isWriteable);
+		}
+		if (!mapped_) {
+			mapped_ = new Exiv2::byte[size_ > 0 ? size_ : 1];
+			if (size_ > 0 && goexivReadAt(handle_, mapped_, size_, 0) != size_) {
+				delete[] mapped_;
+				mapped_ = 0;
+				throw Exiv2::Error(Exiv2::kerErrorMessage, std::string("cannot read image data"));
+			}
+		}
+		return mapped_;
+	}
+
+	int munmap()
+	{
+		if (mem_.get()) {
+			return mem_->munmap();
+		}
+		delete[] mapped_;
+		mapped_ = 0;
+		return 0;
+	}
+
+	long tell() const { return mem_.get() ? mem_->tell() : pos_; }
+	size_t size() const { return mem_.get() ? mem_->size() : size_; }
+	bool isopen() const { return true; }
+	int error() const { return mem_.get() ? mem_->error() : error_; }
+	bool eof() const { return mem_.get() ? mem_->eof() : eof_; }
+	std::string path() const { return "reader"; }
+	void populateFakeData() {}
+
+private:
+	uintptr_t handle_;
+	long size_;
+	long pos_;
+	int error_;
+	bool eof_;
+	Exiv2::byte *mapped_;
+	Exiv2::BasicIo::AutoPtr mem_;
+};
+
+Exiv2Image*
+exiv2_image_factory_open_reader(uintptr_t handle, long size, Exiv2Error **error)
+{
+	try {
+		return new Exiv2Image(Exiv2::ImageFactory::open(Exiv2::BasicIo::AutoPtr(new GoReaderIo(handle, size))));
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return 0;
+}
+
+Exiv2Image*
+exiv2_image_factory_open_sidecar(const char *path, Exiv2Error **error)
+{
+	try {
+		Exiv2::BasicIo::AutoPtr io(new Exiv2::FileIo(path));
+		if (io->open() != 0) {
+			throw Exiv2::Error(Exiv2::kerDataSourceOpenFailed, io->path(), Exiv2::strError());
+		}
+		const bool isXmp = Exiv2::isXmpType(*io, false);
+		io->close();
+		if (!isXmp) {
+			throw Exiv2::Error(Exiv2::kerNotAnImage, "XMP");
+		}
+
+		return new Exiv2Image(Exiv2::Image::AutoPtr(new Exiv2::XmpSidecar(io, false)));
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return 0;
+}
+
+Exiv2Image*
+exiv2_image_factory_new_sidecar(Exiv2Error **error)
+{
+	try {
+		return new Exiv2Image(Exiv2::ImageFactory::create(Exiv2::ImageType::xmp));
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return 0;
+}
+
+// exiv2_image_write_sidecar writes the metadata of an image to an XMP
+// sidecar file. The sidecar converts EXIF and IPTC data to XMP on write.
+void
+exiv2_image_write_sidecar(Exiv2Image *img, const char *path, Exiv2Error **error)
+{
+	try {
+		Exiv2::BasicIo::AutoPtr io(new Exiv2::FileIo(path));
+		// FileIo::open() opens for reading only, so the file must exist
+		// for the sidecar to be written
+		if (io->open("w+b") != 0) {
+			throw Exiv2::Error(Exiv2::kerFileOpenFailed, path, "w+b", Exiv2::strError());
+		}
+		io->close();
+
+		Exiv2::XmpSidecar sidecar(io, false);
+		sidecar.setExifData(img->image->exifData());
+		sidecar.setIptcData(img->image->iptcData());
+		sidecar.setXmpData(img->image->xmpData());
+		sidecar.writeMetadata();
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
 void
 exiv2_image_read_metadata(Exiv2Image *img, Exiv2Error **error)
 {
@@ -121,19 +403,53 @@ exiv2_image_read_metadata(Exiv2Image *img, Exiv2Error **error)
 	}
 }
 
-void
-exiv2_image_set_exif_string(Exiv2Image *img, char *key, char *value, Exiv2Error **error)
+// EDITOR
+
+Exiv2::ExifData&
+Exiv2Editor::exif()
+{
+	if (!exifDirty) {
+		exifData = image->exifData();
+		exifDirty = true;
+	}
+	return exifData;
+}
+
+Exiv2::IptcData&
+Exiv2Editor::iptc()
+{
+	if (!iptcDirty) {
+		iptcData = image->iptcData();
+		iptcDirty = true;
+	}
+	return iptcData;
+}
+
+Exiv2::XmpData&
+Exiv2Editor::xmp()
+{
+	if (!xmpDirty) {
+		xmpData = image->xmpData();
+		xmpDirty = true;
+	}
+	xmpPacketSet = false;
+	return xmpData;
+}
+
+Exiv2Editor*
+exiv2_image_editor(Exiv2Image *img)
 {
-	Exiv2::ExifData exifData = img->image->exifData();
+	return new Exiv2Editor(img->image.get());
+}
 
+void
+exiv2_editor_set_exif_string(Exiv2Editor *ed, char *key, char *value, Exiv2Error **error)
+{
 	try {
-	    Exiv2::Exifdatum& tag = exifData[key];
+		Exiv2::Exifdatum& tag = ed->exif()[key];
 		Exiv2::Value::AutoPtr valueObject = Exiv2::Value::create(Exiv2::asciiString);
 		valueObject->read(value);
 		tag.setValue(valueObject.get());
-
-		img->image->setExifData(exifData);
-		img->image->writeMetadata();
 	} catch (Exiv2::Error &e) {
 		if (error) {
 			*error = new Exiv2Error(e);
@@ -142,18 +458,13 @@ exiv2_image_set_exif_string(Exiv2Image *img, char *key, char *value, Exiv2Error
 }
 
 void
-exiv2_image_set_exif_short(Exiv2Image *img, char *key, char *value, Exiv2Error **error)
+exiv2_editor_set_exif_short(Exiv2Editor *ed, char *key, char *value, Exiv2Error **error)
 {
-	Exiv2::ExifData exifData = img->image->exifData();
-
 	try {
-		Exiv2::Exifdatum& tag = exifData[key];
+		Exiv2::Exifdatum& tag = ed->exif()[key];
 		Exiv2::Value::AutoPtr valueObject = Exiv2::Value::create(Exiv2::unsignedShort);
 		valueObject->read(value);
 		tag.setValue(valueObject.get());
-
-		img->image->setExifData(exifData);
-		img->image->writeMetadata();
 	} catch (Exiv2::Error &e) {
 		if (error) {
 			*error = new Exiv2Error(e);
@@ -162,17 +473,12 @@ exiv2_image_set_exif_short(Exiv2Image *img, char *key, char *value, Exiv2Error *
 }
 
 void
-exiv2_image_set_iptc_string(Exiv2Image *img, char *key, char *value, Exiv2Error **error)
+exiv2_editor_set_iptc_string(Exiv2Editor *ed, char *key, char *value, Exiv2Error **error)
 {
-	Exiv2::IptcData iptcData = img->image->iptcData();
-
 	try {
 		Exiv2::StringValue valueObject;
 		valueObject.read(value);
-		iptcData[key] = valueObject;
-
-		img->image->setIptcData(iptcData);
-		img->image->writeMetadata();
+		ed->iptc()[key] = valueObject;
 	} catch (Exiv2::Error &e) {
 		if (error) {
 			*error = new Exiv2Error(e);
@@ -181,17 +487,27 @@ exiv2_image_set_iptc_string(Exiv2Image *img, char *key, char *value, Exiv2Error
 }
 
 void
-exiv2_image_set_xmp_string(Exiv2Image *img, char *key, char *value, Exiv2Error **error)
+exiv2_editor_set_iptc_short(Exiv2Editor *ed, char *key, char *value, Exiv2Error **error)
 {
-	Exiv2::XmpData xmpData = img->image->xmpData();
+	try {
+		Exiv2::Iptcdatum& tag = ed->iptc()[key];
+		Exiv2::Value::AutoPtr valueObject = Exiv2::Value::create(Exiv2::unsignedShort);
+		valueObject->read(value);
+		tag.setValue(valueObject.get());
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
 
+void
+exiv2_editor_set_xmp_string(Exiv2Editor *ed, char *key, char *value, Exiv2Error **error)
+{
 	try {
 		Exiv2::StringValue valueObject;
 		valueObject.read(value);
-		xmpData[key] = valueObject;
-
-		img->image->setXmpData(xmpData);
-		img->image->writeMetadata();
+		ed->xmp()[key] = valueObject;
 	} catch (Exiv2::Error &e) {
 		if (error) {
 			*error = new Exiv2Error(e);
@@ -199,143 +515,1089 @@ exiv2_image_set_xmp_string(Exiv2Image *img, char *key, char *value, Exiv2Error *
 	}
 }
 
-void
-exiv2_image_set_iptc_short(Exiv2Image *img, char *key, char *value, Exiv2Error **error)
+// makeValue creates a value of the given type, read from data when it is
+// not null and from text otherwise.
+static Exiv2::Value::AutoPtr
+makeValue(int typeId, const char *text, const unsigned char *data, long size)
 {
-    Exiv2::IptcData iptcData = img->image->iptcData();
+	Exiv2::Value::AutoPtr valueObject = Exiv2::Value::create(static_cast<Exiv2::TypeId>(typeId));
 
-    try {
-        Exiv2::Iptcdatum& tag = iptcData[key];
-        Exiv2::Value::AutoPtr valueObject = Exiv2::Value::create(Exiv2::unsignedShort);
-        valueObject->read(value);
-        tag.setValue(valueObject.get());
-
-        img->image->setIptcData(iptcData);
-        img->image->writeMetadata();
-    } catch (Exiv2::Error &e) {
-        if (error) {
-            *error = new Exiv2Error(e);
-        }
-    }
+	int rc;
+	if (data) {
+		rc = valueObject->read(data, size, Exiv2::littleEndian);
+	} else {
+		rc = valueObject->read(text);
+	}
+
+	if (rc != 0) {
+		const char *typeName = Exiv2::TypeInfo::typeName(valueObject->typeId());
+		throw Exiv2::Error(Exiv2::kerErrorMessage, std::string("invalid value for type ") + (typeName ? typeName : "unknown"));
+	}
+
+	return valueObject;
 }
 
-long
-exiv_image_get_size(Exiv2Image *img)
+void
+exiv2_editor_set_exif_value(Exiv2Editor *ed, char *key, int typeId, char *text, unsigned char *data, long size, Exiv2Error **error)
 {
-    return (long)img->image->io().size();
+	try {
+		Exiv2::Value::AutoPtr valueObject = makeValue(typeId, text, data, size);
+		ed->exif()[key].setValue(valueObject.get());
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
 }
 
-unsigned char*
-exiv_image_get_bytes_ptr(Exiv2Image *img)
+void
+exiv2_editor_set_iptc_value(Exiv2Editor *ed, char *key, int typeId, char *text, unsigned char *data, long size, Exiv2Error **error)
 {
-    return img->image->io().mmap();
+	try {
+		Exiv2::Value::AutoPtr valueObject = makeValue(typeId, text, data, size);
+		ed->iptc()[key].setValue(valueObject.get());
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
 }
 
+void
+exiv2_editor_set_xmp_value(Exiv2Editor *ed, char *key, int typeId, char *text, unsigned char *data, long size, Exiv2Error **error)
+{
+	try {
+		Exiv2::Value::AutoPtr valueObject = makeValue(typeId, text, data, size);
+		ed->xmp()[key].setValue(valueObject.get());
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
 
-DEFINE_FREE_FUNCTION(exiv2_image, Exiv2Image*);
+void
+exiv2_editor_add_iptc_string(Exiv2Editor *ed, char *key, char *value, Exiv2Error **error)
+{
+	try {
+		Exiv2::IptcKey iptcKey(key);
+		Exiv2::IptcData &iptcData = ed->iptc();
 
-int exiv2_image_get_pixel_width(Exiv2Image *img) {
-	return img->image->pixelWidth();
-}
+		if (!Exiv2::IptcDataSets::dataSetRepeatable(iptcKey.tag(), iptcKey.record())
+				&& iptcData.findKey(iptcKey) != iptcData.end()) {
+			throw Exiv2::Error(Exiv2::kerErrorMessage, iptcKey.key() + " is not repeatable");
+		}
 
-int exiv2_image_get_pixel_height(Exiv2Image *img) {
-	return img->image->pixelHeight();
+		Exiv2::StringValue valueObject;
+		valueObject.read(value);
+		iptcData.add(iptcKey, &valueObject);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
 }
 
-const unsigned char* exiv2_image_icc_profile(Exiv2Image *img)
+void
+exiv2_editor_iptc_strip_all(Exiv2Editor *ed, char *key, Exiv2Error **error)
 {
-	if (img->image->iccProfileDefined()) {
-		return img->image->iccProfile()->pData_;
+	try {
+		Exiv2::IptcKey iptcKey(key);
+		Exiv2::IptcData &iptcData = ed->iptc();
+		Exiv2::IptcData::iterator pos;
+		while ((pos = iptcData.findKey(iptcKey)) != iptcData.end()) {
+			iptcData.erase(pos);
+		}
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
 	}
-	return NULL;
 }
 
-long exiv2_image_icc_profile_size(Exiv2Image *img)
+void
+exiv2_editor_set_xmp_array(Exiv2Editor *ed, char *key, int typeId, char **values, int len, Exiv2Error **error)
 {
-	if (img->image->iccProfileDefined()) {
-		return img->image->iccProfile()->size_;
+	try {
+		Exiv2::XmpArrayValue valueObject(static_cast<Exiv2::TypeId>(typeId));
+		for (int i = 0; i < len; i++) {
+			valueObject.read(values[i]);
+		}
+		ed->xmp()[key].setValue(&valueObject);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
 	}
-	return 0;
 }
 
-// XMP
-Exiv2XmpData*
-exiv2_image_get_xmp_data(const Exiv2Image *img)
+void
+exiv2_editor_set_xmp_lang_alt(Exiv2Editor *ed, char *key, char *lang, char *value, Exiv2Error **error)
 {
-	return new Exiv2XmpData(img->image->xmpData());
+	try {
+		Exiv2::XmpKey xmpKey(key);
+		Exiv2::XmpData &xmpData = ed->xmp();
+
+		// keep the other languages of an existing alternative
+		Exiv2::LangAltValue valueObject;
+		Exiv2::XmpData::iterator pos = xmpData.findKey(xmpKey);
+		if (pos != xmpData.end()) {
+			const Exiv2::LangAltValue *existing = dynamic_cast<const Exiv2::LangAltValue*>(&pos->value());
+			if (existing) {
+				valueObject.value_ = existing->value_;
+			}
+		}
+
+		valueObject.value_[lang] = value;
+		xmpData[key].setValue(&valueObject);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
 }
 
-Exiv2XmpDatum*
-exiv2_xmp_data_find_key(const Exiv2XmpData *data, const char *key, Exiv2Error **error)
+void
+exiv2_editor_set_xmp_struct(Exiv2Editor *ed, char *key, Exiv2Error **error)
 {
 	try {
-		const Exiv2::XmpData::const_iterator it = data->data.findKey(Exiv2::XmpKey(key));
-		if (it == data->data.end()) {
-			return 0;
+		Exiv2::XmpKey xmpKey(key);
+		Exiv2::XmpData &xmpData = ed->xmp();
+		if (xmpData.findKey(xmpKey) != xmpData.end()) {
+			return;
 		}
 
-		return new Exiv2XmpDatum(*it);
+		Exiv2::XmpTextValue valueObject;
+		valueObject.setXmpStruct();
+		xmpData.add(xmpKey, &valueObject);
 	} catch (Exiv2::Error &e) {
 		if (error) {
 			*error = new Exiv2Error(e);
 		}
+	}
+}
+
+void
+exiv2_editor_set_xmp_packet(Exiv2Editor *ed, const char *packet, long size, Exiv2Error **error)
+{
+	try {
+		const std::string xmpPacket = packet ? std::string(packet, size) : std::string();
+
+		Exiv2::XmpData xmpData;
+		if (Exiv2::XmpParser::decode(xmpData, xmpPacket) != 0) {
+			throw Exiv2::Error(Exiv2::kerErrorMessage, std::string("cannot parse XMP packet"));
+		}
 
-		return 0;
+		ed->xmp() = xmpData;
+		ed->xmpPacket = xmpPacket;
+		ed->xmpPacketSet = true;
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
 	}
 }
 
-Exiv2XmpDatumIterator* exiv2_xmp_data_iterator(const Exiv2XmpData *data)
+void
+exiv2_editor_merge_xmp_sidecar(Exiv2Editor *ed, const char *path, Exiv2Error **error)
 {
-	return new Exiv2XmpDatumIterator(data->data.begin(), data->data.end());
+	try {
+		Exiv2::BasicIo::AutoPtr io(new Exiv2::FileIo(path));
+		Exiv2::XmpSidecar sidecar(io, false);
+		sidecar.readMetadata();
+
+		Exiv2::XmpData &xmpData = ed->xmp();
+		const Exiv2::XmpData &sidecarData = sidecar.xmpData();
+		for (Exiv2::XmpData::const_iterator it = sidecarData.begin(); it != sidecarData.end(); ++it) {
+			Exiv2::XmpData::iterator pos = xmpData.findKey(Exiv2::XmpKey(it->key()));
+			if (pos != xmpData.end()) {
+				pos->setValue(&it->value());
+			} else {
+				xmpData.add(*it);
+			}
+		}
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
 }
 
-bool Exiv2XmpDatumIterator::has_next() const
+// mergeData adds the source data to the target. Keys that already exist in
+// the target are replaced if overwrite is set, and kept otherwise. All the
+// entries of a repeated key are handled together.
+template <typename Data, typename Key>
+static void
+mergeData(Data &target, const Data &converted, bool overwrite)
 {
-	return it != end;
+	std::set<std::string> taken;
+	for (typename Data::const_iterator it = converted.begin(); it != converted.end(); ++it) {
+		if (overwrite || target.findKey(Key(it->key())) == target.end()) {
+			taken.insert(it->key());
+		}
+	}
+
+	for (typename Data::iterator it = target.begin(); it != target.end();) {
+		if (taken.count(it->key())) {
+			it = target.erase(it);
+		} else {
+			++it;
+		}
+	}
+
+	for (typename Data::const_iterator it = converted.begin(); it != converted.end(); ++it) {
+		if (taken.count(it->key())) {
+			target.add(*it);
+		}
+	}
+}
+
+void
+exiv2_editor_sync_exif_to_xmp(Exiv2Editor *ed, int overwrite, Exiv2Error **error)
+{
+	try {
+		Exiv2::XmpData converted;
+		Exiv2::copyExifToXmp(ed->readExif(), converted);
+		mergeData<Exiv2::XmpData, Exiv2::XmpKey>(ed->xmp(), converted, overwrite != 0);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+void
+exiv2_editor_sync_iptc_to_xmp(Exiv2Editor *ed, const char *charset, int overwrite, Exiv2Error **error)
+{
+	try {
+		Exiv2::XmpData converted;
+		Exiv2::copyIptcToXmp(ed->readIptc(), converted, charset);
+		mergeData<Exiv2::XmpData, Exiv2::XmpKey>(ed->xmp(), converted, overwrite != 0);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+void
+exiv2_editor_sync_xmp_to_exif(Exiv2Editor *ed, int overwrite, Exiv2Error **error)
+{
+	try {
+		Exiv2::ExifData converted;
+		Exiv2::copyXmpToExif(ed->readXmp(), converted);
+		mergeData<Exiv2::ExifData, Exiv2::ExifKey>(ed->exif(), converted, overwrite != 0);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+void
+exiv2_editor_sync_xmp_to_iptc(Exiv2Editor *ed, int overwrite, Exiv2Error **error)
+{
+	try {
+		Exiv2::IptcData converted;
+		Exiv2::copyXmpToIptc(ed->readXmp(), converted);
+		if (converted.empty()) {
+			return;
+		}
+
+		Exiv2::IptcData &iptcData = ed->iptc();
+		mergeData<Exiv2::IptcData, Exiv2::IptcKey>(iptcData, converted, overwrite != 0);
+
+		// XMP values are converted to UTF-8 strings
+		const Exiv2::IptcKey charsetKey("Iptc.Envelope.CharacterSet");
+		if (iptcData.findKey(charsetKey) == iptcData.end()) {
+			iptcData[charsetKey.key()] = "\033%G";
+		}
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+// keyMatches reports whether a key matches one of the patterns. A pattern
+// matches the key itself and the fields and items of an XMP key. A pattern
+// ending with a dot matches all the keys starting with it.
+static bool
+keyMatches(const std::string &key, char **patterns, int len)
+{
+	for (int i = 0; i < len; i++) {
+		const std::string pattern(patterns[i]);
+		if (pattern.empty() || key.compare(0, pattern.size(), pattern) != 0) {
+			continue;
+		}
+		if (key.size() == pattern.size() || pattern[pattern.size() - 1] == '.'
+				|| key[pattern.size()] == '/' || key[pattern.size()] == '[') {
+			return true;
+		}
+	}
+	return false;
+}
+
+// copyMatching copies the source entries selected by the include and
+// exclude patterns to the target, replacing the target entries with the
+// same key. An empty include list selects all the entries.
+template <typename Data, typename Key>
+static void
+copyMatching(Data &target, const Data &source, char **include, int includeLen, char **exclude, int excludeLen)
+{
+	Data matching;
+	for (typename Data::const_iterator it = source.begin(); it != source.end(); ++it) {
+		const std::string key = it->key();
+		if ((includeLen == 0 || keyMatches(key, include, includeLen)) && !keyMatches(key, exclude, excludeLen)) {
+			matching.add(*it);
+		}
+	}
+
+	mergeData<Data, Key>(target, matching, true);
+}
+
+void
+exiv2_editor_copy_exif(Exiv2Editor *ed, const Exiv2Image *src, char **include, int includeLen, char **exclude, int excludeLen, Exiv2Error **error)
+{
+	try {
+		copyMatching<Exiv2::ExifData, Exiv2::ExifKey>(ed->exif(), src->image->exifData(), include, includeLen, exclude, excludeLen);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+void
+exiv2_editor_copy_iptc(Exiv2Editor *ed, const Exiv2Image *src, char **include, int includeLen, char **exclude, int excludeLen, Exiv2Error **error)
+{
+	try {
+		copyMatching<Exiv2::IptcData, Exiv2::IptcKey>(ed->iptc(), src->image->iptcData(), include, includeLen, exclude, excludeLen);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+void
+exiv2_editor_copy_xmp(Exiv2Editor *ed, const Exiv2Image *src, char **include, int includeLen, char **exclude, int excludeLen, Exiv2Error **error)
+{
+	try {
+		copyMatching<Exiv2::XmpData, Exiv2::XmpKey>(ed->xmp(), src->image->xmpData(), include, includeLen, exclude, excludeLen);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+// exiv2_editor_fix_dimensions sets the dimension tags that are present to
+// the pixel size of the image.
+void
+exiv2_editor_fix_dimensions(Exiv2Editor *ed, Exiv2Error **error)
+{
+	const int width = ed->image->pixelWidth();
+	const int height = ed->image->pixelHeight();
+	if (width <= 0 || height <= 0) {
+		return;
+	}
+
+	try {
+		const Exiv2::ExifKey widthKey("Exif.Photo.PixelXDimension");
+		const Exiv2::ExifKey heightKey("Exif.Photo.PixelYDimension");
+		const Exiv2::ExifData &exifData = ed->readExif();
+		if (exifData.findKey(widthKey) != exifData.end() || exifData.findKey(heightKey) != exifData.end()) {
+			ed->exif()[widthKey.key()] = static_cast<uint32_t>(width);
+			ed->exif()[heightKey.key()] = static_cast<uint32_t>(height);
+		}
+
+		const char *xmpKeys[][2] = {
+			{"Xmp.exif.PixelXDimension", "Xmp.exif.PixelYDimension"},
+			{"Xmp.tiff.ImageWidth", "Xmp.tiff.ImageLength"},
+		};
+		for (size_t i = 0; i < sizeof(xmpKeys) / sizeof(xmpKeys[0]); i++) {
+			const Exiv2::XmpData &xmpData = ed->readXmp();
+			if (xmpData.findKey(Exiv2::XmpKey(xmpKeys[i][0])) != xmpData.end()
+					|| xmpData.findKey(Exiv2::XmpKey(xmpKeys[i][1])) != xmpData.end()) {
+				ed->xmp()[xmpKeys[i][0]] = width;
+				ed->xmp()[xmpKeys[i][1]] = height;
+			}
+		}
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+void
+exiv2_editor_set_comment(Exiv2Editor *ed, const char *comment)
+{
+	ed->comment = comment;
+	ed->commentDirty = true;
+}
+
+void
+exiv2_editor_set_icc_profile(Exiv2Editor *ed, const unsigned char *profile, long size)
+{
+	ed->iccProfile = profile ? std::string(reinterpret_cast<const char*>(profile), size) : std::string();
+	ed->iccDirty = true;
+}
+
+// stripPrefix removes all the entries whose key starts with prefix.
+template <typename Data>
+static void
+stripPrefix(Data &data, const std::string &prefix)
+{
+	for (typename Data::iterator it = data.begin(); it != data.end();) {
+		if (it->key().compare(0, prefix.size(), prefix) == 0) {
+			it = data.erase(it);
+		} else {
+			++it;
+		}
+	}
+}
+
+void
+exiv2_editor_exif_strip_prefix(Exiv2Editor *ed, char *prefix)
+{
+	stripPrefix(ed->exif(), prefix);
+}
+
+void
+exiv2_editor_iptc_strip_prefix(Exiv2Editor *ed, char *prefix)
+{
+	stripPrefix(ed->iptc(), prefix);
+}
+
+void
+exiv2_editor_xmp_strip_prefix(Exiv2Editor *ed, char *prefix)
+{
+	stripPrefix(ed->xmp(), prefix);
+}
+
+void
+exiv2_editor_exif_strip_key(Exiv2Editor *ed, char *key, Exiv2Error **error)
+{
+	try {
+		Exiv2::ExifKey exifKey(key);
+		Exiv2::ExifData &exifData = ed->exif();
+		Exiv2::ExifData::iterator pos = exifData.findKey(exifKey);
+		if (pos != exifData.end()) {
+			exifData.erase(pos);
+		}
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+void
+exiv2_editor_iptc_strip_key(Exiv2Editor *ed, char *key, Exiv2Error **error)
+{
+	try {
+		Exiv2::IptcKey iptcKey(key);
+		Exiv2::IptcData &iptcData = ed->iptc();
+		Exiv2::IptcData::iterator pos = iptcData.findKey(iptcKey);
+		if (pos != iptcData.end()) {
+			iptcData.erase(pos);
+		}
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+void
+exiv2_editor_xmp_strip_key(Exiv2Editor *ed, char *key, Exiv2Error **error)
+{
+	try {
+		Exiv2::XmpKey xmpKey(key);
+		Exiv2::XmpData &xmpData = ed->xmp();
+		Exiv2::XmpData::iterator pos = xmpData.findKey(xmpKey);
+		if (pos != xmpData.end()) {
+			xmpData.erase(pos);
+		}
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+void
+exiv2_editor_commit(Exiv2Editor *ed, Exiv2Error **error)
+{
+	if (!ed->exifDirty && !ed->iptcDirty && !ed->xmpDirty && !ed->iccDirty && !ed->commentDirty) {
+		return;
+	}
+
+	// The changes are applied to the image before it is written, so the
+	// metadata they replace is kept to be restored if the write fails.
+	Exiv2::Image *image = ed->image;
+	Exiv2::ExifData exifData;
+	Exiv2::IptcData iptcData;
+	Exiv2::XmpData xmpData;
+	std::string xmpPacket;
+	bool writeXmpFromPacket = false;
+	std::string iccProfile;
+	std::string comment;
+	if (ed->exifDirty) {
+		exifData = image->exifData();
+	}
+	if (ed->iptcDirty) {
+		iptcData = image->iptcData();
+	}
+	if (ed->xmpDirty) {
+		xmpData = image->xmpData();
+		xmpPacket = image->xmpPacket();
+		writeXmpFromPacket = image->writeXmpFromPacket();
+	}
+	if (ed->iccDirty && image->iccProfileDefined()) {
+		iccProfile.assign(reinterpret_cast<const char*>(image->iccProfile()->pData_), image->iccProfile()->size_);
+	}
+	if (ed->commentDirty) {
+		comment = image->comment();
+	}
+
+	try {
+		if (ed->exifDirty) {
+			image->setExifData(ed->exifData);
+		}
+		if (ed->iptcDirty) {
+			image->setIptcData(ed->iptcData);
+		}
+		if (ed->xmpDirty && ed->xmpPacketSet) {
+			image->setXmpPacket(ed->xmpPacket);
+			image->writeXmpFromPacket(true);
+		} else if (ed->xmpDirty) {
+			image->setXmpData(ed->xmpData);
+			image->writeXmpFromPacket(false);
+		}
+		if (ed->iccDirty && ed->iccProfile.empty()) {
+			image->clearIccProfile();
+		} else if (ed->iccDirty) {
+			Exiv2::DataBuf profile(reinterpret_cast<const Exiv2::byte*>(ed->iccProfile.data()), ed->iccProfile.size());
+			image->setIccProfile(profile);
+		}
+		if (ed->commentDirty && ed->comment.empty()) {
+			image->clearComment();
+		} else if (ed->commentDirty) {
+			image->setComment(ed->comment);
+		}
+		image->writeMetadata();
+	} catch (Exiv2::Error &e) {
+		// Only the changed families are restored, as some formats throw
+		// when setting a family they don't support. Restoring must not
+		// throw again, the error of the write is the one reported.
+		try {
+			if (ed->exifDirty) {
+				image->setExifData(exifData);
+			}
+			if (ed->iptcDirty) {
+				image->setIptcData(iptcData);
+			}
+			if (ed->xmpDirty) {
+				image->setXmpData(xmpData);
+				// assigned directly, setXmpPacket would parse the packet again
+				image->xmpPacket() = xmpPacket;
+				image->writeXmpFromPacket(writeXmpFromPacket);
+			}
+			if (ed->iccDirty && iccProfile.empty()) {
+				image->clearIccProfile();
+			} else if (ed->iccDirty) {
+				Exiv2::DataBuf profile(reinterpret_cast<const Exiv2::byte*>(iccProfile.data()), iccProfile.size());
+				image->setIccProfile(profile, false);
+			}
+			if (ed->commentDirty && comment.empty()) {
+				image->clearComment();
+			} else if (ed->commentDirty) {
+				image->setComment(comment);
+			}
+		} catch (...) {
+		}
+
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+DEFINE_FREE_FUNCTION(exiv2_editor, Exiv2Editor*);
+
+long
+exiv_image_get_size(Exiv2Image *img)
+{
+    return (long)img->image->io().size();
+}
+
+unsigned char*
+exiv_image_get_bytes_ptr(Exiv2Image *img)
+{
+	try {
+		return img->image->io().mmap();
+	} catch (Exiv2::Error &e) {
+		return 0;
+	}
+}
+
+
+DEFINE_FREE_FUNCTION(exiv2_image, Exiv2Image*);
+
+void
+exiv2_image_io_open(Exiv2Image *img, Exiv2Error **error)
+{
+	try {
+		Exiv2::BasicIo &io = img->image->io();
+		if (io.open() != 0) {
+			throw Exiv2::Error(Exiv2::kerDataSourceOpenFailed, io.path(), Exiv2::strError());
+		}
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+long
+exiv2_image_io_read(Exiv2Image *img, unsigned char *buf, long size, Exiv2Error **error)
+{
+	try {
+		Exiv2::BasicIo &io = img->image->io();
+		const long n = io.read(buf, size);
+		if (io.error()) {
+			throw Exiv2::Error(Exiv2::kerFailedToReadImageData);
+		}
+		return n;
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return 0;
+}
+
+void
+exiv2_image_io_close(Exiv2Image *img)
+{
+	img->image->io().close();
+}
+
+int exiv2_image_get_pixel_width(Exiv2Image *img) {
+	return img->image->pixelWidth();
+}
+
+int exiv2_image_get_pixel_height(Exiv2Image *img) {
+	return img->image->pixelHeight();
+}
+
+const unsigned char* exiv2_image_icc_profile(Exiv2Image *img)
+{
+	if (img->image->iccProfileDefined()) {
+		return img->image->iccProfile()->pData_;
+	}
+	return NULL;
+}
+
+long exiv2_image_icc_profile_size(Exiv2Image *img)
+{
+	if (img->image->iccProfileDefined()) {
+		return img->image->iccProfile()->size_;
+	}
+	return 0;
+}
+
+// imageTypes lists the Exiv2 image types in the order of the Go ImageType
+// constants. TIFF based RAW formats are all reported as TIFF by Exiv2.
+static const int imageTypes[] = {
+	Exiv2::ImageType::none,
+	Exiv2::ImageType::jpeg,
+	Exiv2::ImageType::exv,
+	Exiv2::ImageType::cr2,
+	Exiv2::ImageType::crw,
+	Exiv2::ImageType::tiff,
+	Exiv2::ImageType::mrw,
+	Exiv2::ImageType::png,
+	Exiv2::ImageType::raf,
+	Exiv2::ImageType::orf,
+	Exiv2::ImageType::xmp,
+	Exiv2::ImageType::gif,
+	Exiv2::ImageType::psd,
+	Exiv2::ImageType::tga,
+	Exiv2::ImageType::bmp,
+	Exiv2::ImageType::jp2,
+	Exiv2::ImageType::rw2,
+	Exiv2::ImageType::pgf,
+	Exiv2::ImageType::webp,
+	Exiv2::ImageType::eps,
+};
+
+static int
+imageTypeIndex(int type)
+{
+	for (size_t i = 0; i < sizeof(imageTypes) / sizeof(imageTypes[0]); i++) {
+		if (imageTypes[i] == type) {
+			return i;
+		}
+	}
+	return 0;
+}
+
+int
+exiv2_image_type(const Exiv2Image *img)
+{
+	try {
+		return imageTypeIndex(Exiv2::ImageFactory::getType(img->image->io()));
+	} catch (Exiv2::Error &e) {
+		return 0;
+	}
+}
+
+int
+exiv2_detect_type(const unsigned char *data, long size)
+{
+	try {
+		return imageTypeIndex(Exiv2::ImageFactory::getType(data, size));
+	} catch (Exiv2::Error &e) {
+		return 0;
+	}
+}
+
+char*
+exiv2_image_mime_type(const Exiv2Image *img)
+{
+	return strdup(img->image->mimeType().c_str());
+}
+
+int
+exiv2_image_check_mode(const Exiv2Image *img, int metadataId)
+{
+	return img->image->checkMode(static_cast<Exiv2::MetadataId>(metadataId));
+}
+
+char*
+exiv2_image_comment(const Exiv2Image *img)
+{
+	return strdup(img->image->comment().c_str());
+}
+
+// EXIF THUMBNAIL
+
+unsigned char*
+exiv2_image_exif_thumbnail(const Exiv2Image *img, char **mime, long *size, Exiv2Error **error)
+{
+	try {
+		Exiv2::ExifThumbC thumb(img->image->exifData());
+		Exiv2::DataBuf buf = thumb.copy();
+		if (buf.size_ == 0) {
+			*size = 0;
+			return 0;
+		}
+
+		*mime = strdup(thumb.mimeType());
+		*size = buf.size_;
+		unsigned char *data = (unsigned char*)malloc(buf.size_);
+		memcpy(data, buf.pData_, buf.size_);
+
+		return data;
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return 0;
+}
+
+void
+exiv2_editor_set_exif_thumbnail(Exiv2Editor *ed, const unsigned char *data, long size, Exiv2Error **error)
+{
+	try {
+		Exiv2::ExifThumb thumb(ed->exif());
+		thumb.setJpegThumbnail(data, size);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+void
+exiv2_editor_remove_exif_thumbnail(Exiv2Editor *ed, Exiv2Error **error)
+{
+	try {
+		Exiv2::ExifThumb thumb(ed->exif());
+		thumb.erase();
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+// PREVIEWS
+
+Exiv2PreviewProperties*
+exiv2_image_previews(const Exiv2Image *img, int *len, Exiv2Error **error)
+{
+	try {
+		Exiv2::PreviewManager manager(*img->image);
+		const Exiv2::PreviewPropertiesList list = manager.getPreviewProperties();
+
+		*len = list.size();
+		Exiv2PreviewProperties *props = (Exiv2PreviewProperties*)malloc(list.size() * sizeof(Exiv2PreviewProperties));
+		for (size_t i = 0; i < list.size(); i++) {
+			props[i].mime_type = strdup(list[i].mimeType_.c_str());
+			props[i].extension = strdup(list[i].extension_.c_str());
+			props[i].size = list[i].size_;
+			props[i].width = list[i].width_;
+			props[i].height = list[i].height_;
+			props[i].id = list[i].id_;
+		}
+
+		return props;
+	} catch (Exiv2::Error &e) {
+		*len = 0;
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return 0;
+}
+
+void
+exiv2_preview_properties_free(Exiv2PreviewProperties *props, int len)
+{
+	for (int i = 0; i < len; i++) {
+		free(props[i].mime_type);
+		free(props[i].extension);
+	}
+	free(props);
+}
+
+unsigned char*
+exiv2_image_preview(const Exiv2Image *img, int id, long *size, Exiv2Error **error)
+{
+	try {
+		Exiv2::PreviewManager manager(*img->image);
+		const Exiv2::PreviewPropertiesList list = manager.getPreviewProperties();
+
+		for (size_t i = 0; i < list.size(); i++) {
+			if (list[i].id_ != static_cast<Exiv2::PreviewId>(id)) {
+				continue;
+			}
+
+			const Exiv2::PreviewImage preview = manager.getPreviewImage(list[i]);
+			*size = preview.size();
+			unsigned char *data = (unsigned char*)malloc(preview.size());
+			memcpy(data, preview.pData(), preview.size());
+
+			return data;
+		}
+
+		throw Exiv2::Error(Exiv2::kerErrorMessage, std::string("preview not found"));
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return 0;
+}
+
+// XMP
+Exiv2XmpData*
+exiv2_image_get_xmp_data(const Exiv2Image *img)
+{
+	return new Exiv2XmpData(img->image->xmpData());
+}
+
+Exiv2XmpDatum*
+exiv2_xmp_data_find_key(const Exiv2XmpData *data, const char *key, Exiv2Error **error)
+{
+	try {
+		const Exiv2::XmpData::const_iterator it = data->data.findKey(Exiv2::XmpKey(key));
+		if (it == data->data.end()) {
+			return 0;
+		}
+
+		return new Exiv2XmpDatum(*it);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+
+		return 0;
+	}
+}
+
+Exiv2XmpDatumIterator* exiv2_xmp_data_iterator(const Exiv2XmpData *data)
+{
+	return new Exiv2XmpDatumIterator(data->data.begin(), data->data.end());
+}
+
+bool Exiv2XmpDatumIterator::has_next() const
+{
+	return it != end;
+}
+
+int exiv2_xmp_data_iterator_has_next(const Exiv2XmpDatumIterator *iter)
+{
+	return iter->has_next() ? 1 : 0;
+}
+
+Exiv2XmpDatum* Exiv2XmpDatumIterator::next()
+{
+	if (it == end) {
+		return 0;
+	}
+	return new Exiv2XmpDatum(*it++);
+}
+
+Exiv2XmpDatum* exiv2_xmp_datum_iterator_next(Exiv2XmpDatumIterator *iter)
+{
+	return iter->next();
+}
+
+DEFINE_FREE_FUNCTION(exiv2_xmp_data, Exiv2XmpData*);
+
+const char* exiv2_xmp_datum_key(const Exiv2XmpDatum *datum)
+{
+	return strdup(datum->datum.key().c_str());
+}
+
+char*
+exiv2_xmp_datum_to_string(const Exiv2XmpDatum *datum)
+{
+    Exiv2::TypeId typeId = datum->datum.typeId();
+
+    std::string strval;
+
+    if (typeId == Exiv2::xmpBag) {
+        strval = datum->datum.toString();
+    } else {
+        strval = datum->datum.toString(0);
+    }
+
+	return strdup(strval.c_str());
+}
+
+DEFINE_FREE_FUNCTION(exiv2_xmp_datum, Exiv2XmpDatum*);
+
+void
+exiv2_xmp_register_ns(const char *uri, const char *prefix, Exiv2Error **error)
+{
+	try {
+		Exiv2::XmpProperties::registerNs(uri, prefix);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+void
+exiv2_xmp_unregister_ns(const char *prefix, Exiv2Error **error)
+{
+	try {
+		// ns() throws if the prefix is unknown
+		Exiv2::XmpProperties::unregisterNs(Exiv2::XmpProperties::ns(prefix));
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+char*
+exiv2_xmp_ns(const char *prefix)
+{
+	try {
+		return strdup(Exiv2::XmpProperties::ns(prefix).c_str());
+	} catch (Exiv2::Error &e) {
+		return 0;
+	}
 }
 
-int exiv2_xmp_data_iterator_has_next(const Exiv2XmpDatumIterator *iter)
+int
+exiv2_xmp_namespaces(char ***prefixes, char ***uris)
 {
-	return iter->has_next() ? 1 : 0;
-}
+	Exiv2::Dictionary dict;
+	Exiv2::XmpProperties::registeredNamespaces(dict);
 
-Exiv2XmpDatum* Exiv2XmpDatumIterator::next()
-{
-	if (it == end) {
-		return 0;
+	*prefixes = (char**)malloc(dict.size() * sizeof(char*));
+	*uris = (char**)malloc(dict.size() * sizeof(char*));
+
+	int n = 0;
+	for (Exiv2::Dictionary::const_iterator it = dict.begin(); it != dict.end(); ++it, ++n) {
+		(*prefixes)[n] = strdup(it->first.c_str());
+		(*uris)[n] = strdup(it->second.c_str());
 	}
-	return new Exiv2XmpDatum(*it++);
+
+	return n;
 }
 
-Exiv2XmpDatum* exiv2_xmp_datum_iterator_next(Exiv2XmpDatumIterator *iter)
+char*
+exiv2_image_xmp_packet(const Exiv2Image *img, long *size)
 {
-	return iter->next();
-}
+	const std::string &packet = img->image->xmpPacket();
 
-DEFINE_FREE_FUNCTION(exiv2_xmp_data, Exiv2XmpData*);
+	*size = packet.size();
+	char *buf = (char*)malloc(packet.size());
+	memcpy(buf, packet.data(), packet.size());
 
-const char* exiv2_xmp_datum_key(const Exiv2XmpDatum *datum)
-{
-	return strdup(datum->datum.key().c_str());
+	return buf;
 }
 
 char*
-exiv2_xmp_datum_to_string(const Exiv2XmpDatum *datum)
+exiv2_xmp_data_serialize(const Exiv2XmpData *data, unsigned int flags, unsigned int padding, long *size, Exiv2Error **error)
 {
-    Exiv2::TypeId typeId = datum->datum.typeId();
+	try {
+		std::string packet;
+		if (Exiv2::XmpParser::encode(packet, data->data, flags, padding) != 0) {
+			throw Exiv2::Error(Exiv2::kerErrorMessage, std::string("cannot serialize XMP data"));
+		}
 
-    std::string strval;
+		*size = packet.size();
+		char *buf = (char*)malloc(packet.size());
+		memcpy(buf, packet.data(), packet.size());
 
-    if (typeId == Exiv2::xmpBag) {
-        strval = datum->datum.toString();
-    } else {
-        strval = datum->datum.toString(0);
-    }
+		return buf;
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
 
-	return strdup(strval.c_str());
+	return 0;
 }
 
-DEFINE_FREE_FUNCTION(exiv2_xmp_datum, Exiv2XmpDatum*);
+// exiv2_xmp_parse returns an in-memory XMP sidecar holding the parsed packet.
+Exiv2Image*
+exiv2_xmp_parse(const char *packet, long size, Exiv2Error **error)
+{
+	try {
+		const std::string xmpPacket = packet ? std::string(packet, size) : std::string();
+
+		Exiv2::Image::AutoPtr image = Exiv2::ImageFactory::create(Exiv2::ImageType::xmp);
+		if (Exiv2::XmpParser::decode(image->xmpData(), xmpPacket) != 0) {
+			throw Exiv2::Error(Exiv2::kerErrorMessage, std::string("cannot parse XMP packet"));
+		}
+
+		return new Exiv2Image(image);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return 0;
+}
 
 // IPTC
 
@@ -462,66 +1724,6 @@ Exiv2ExifDatum* exiv2_exif_datum_iterator_next(Exiv2ExifDatumIterator *iter)
 	return iter->next();
 }
 
-void
-exiv2_exif_strip_key(Exiv2Image *img, char *key, Exiv2Error **error)
-{
-    Exiv2::ExifData exifData = img->image->exifData();
-
-    try {
-        Exiv2::ExifData::iterator pos = exifData.findKey(Exiv2::ExifKey(key));
-        if (pos == exifData.end()) {
-            return;
-        }
-        exifData.erase(pos);
-        img->image->setExifData(exifData);
-        img->image->writeMetadata();
-    } catch (Exiv2::Error &e) {
-        if (error) {
-            *error = new Exiv2Error(e);
-        }
-    }
-}
-
-void
-exiv2_iptc_strip_key(Exiv2Image *img, char *key, Exiv2Error **error)
-{
-    Exiv2::IptcData iptcData = img->image->iptcData();
-
-    try {
-        Exiv2::IptcData::iterator pos = iptcData.findKey(Exiv2::IptcKey(key));
-        if (pos == iptcData.end()) {
-            return;
-        }
-        iptcData.erase(pos);
-        img->image->setIptcData(iptcData);
-        img->image->writeMetadata();
-    } catch (Exiv2::Error &e) {
-        if (error) {
-            *error = new Exiv2Error(e);
-        }
-    }
-}
-
-void
-exiv2_xmp_strip_key(Exiv2Image *img, char *key, Exiv2Error **error)
-{
-    Exiv2::XmpData xmpData = img->image->xmpData();
-
-    try {
-        Exiv2::XmpData::iterator pos = xmpData.findKey(Exiv2::XmpKey(key));
-        if (pos == xmpData.end()) {
-            return;
-        }
-        xmpData.erase(pos);
-        img->image->setXmpData(xmpData);
-        img->image->writeMetadata();
-    } catch (Exiv2::Error &e) {
-        if (error) {
-            *error = new Exiv2Error(e);
-        }
-    }
-}
-
 void
 exiv2_exif_strip_data(Exiv2Image *img, char **keysToRemove, int len, Exiv2Error **error) {
     Exiv2::ExifData exifData = img->image->exifData();
@@ -550,11 +1752,12 @@ exiv2_iptc_strip_data(Exiv2Image *img, char **keysToRemove, int len, Exiv2Error
 
     for (int i = 0; i < len; i++) {
         try {
-            Exiv2::IptcData::iterator pos = iptcData.findKey(Exiv2::IptcKey(keysToRemove[i]));
-            if (pos == iptcData.end()) {
-                continue;
+            // repeatable datasets may occur several times, remove them all
+            Exiv2::IptcKey key(keysToRemove[i]);
+            Exiv2::IptcData::iterator pos;
+            while ((pos = iptcData.findKey(key)) != iptcData.end()) {
+                iptcData.erase(pos);
             }
-            iptcData.erase(pos);
         } catch (Exiv2::Error &e) {
             if (error) {
                 *error = new Exiv2Error(e);
@@ -585,6 +1788,7 @@ exiv2_xmp_strip_data(Exiv2Image *img, char **keysToRemove, int len, Exiv2Error *
     }
     // Finally, write the remaining Xmp data to the image file
     img->image->setXmpData(xmpData);
+    img->image->writeXmpFromPacket(false);
     img->image->writeMetadata();
 }
 
@@ -601,8 +1805,306 @@ const char* exiv2_exif_datum_to_string(const Exiv2ExifDatum *datum)
 	return strdup(strval.c_str());
 }
 
+const char* exiv2_exif_datum_print(const Exiv2ExifDatum *datum, const Exiv2ExifData *data)
+{
+	try {
+		return strdup(datum->datum.print(&data->data).c_str());
+	} catch (Exiv2::Error &e) {
+		return strdup("");
+	}
+}
+
 DEFINE_FREE_FUNCTION(exiv2_exif_datum, Exiv2ExifDatum*);
 
+typedef Exiv2::ExifData::const_iterator (*EasyAccessFunction)(const Exiv2::ExifData&);
+
+// easyAccessFunctions lists the Exiv2 easy access functions in the order of
+// the Go easyAccess constants.
+static const EasyAccessFunction easyAccessFunctions[] = {
+	Exiv2::make,
+	Exiv2::model,
+	Exiv2::serialNumber,
+	Exiv2::lensName,
+	Exiv2::focalLength,
+	Exiv2::fNumber,
+	Exiv2::exposureTime,
+	Exiv2::isoSpeed,
+	Exiv2::flash,
+	Exiv2::whiteBalance,
+	Exiv2::exposureBiasValue,
+};
+
+Exiv2ExifDatum*
+exiv2_exif_data_easy_access(const Exiv2ExifData *data, int field, Exiv2Error **error)
+{
+	if (field < 0 || field >= (int)(sizeof(easyAccessFunctions) / sizeof(easyAccessFunctions[0]))) {
+		return 0;
+	}
+
+	try {
+		const Exiv2::ExifData::const_iterator it = easyAccessFunctions[field](data->data);
+		if (it == data->data.end()) {
+			return 0;
+		}
+
+		return new Exiv2ExifDatum(*it);
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+
+		return 0;
+	}
+}
+
+// VALUES
+
+const Exiv2Value*
+exiv2_xmp_datum_value(const Exiv2XmpDatum *datum)
+{
+	return &datum->value;
+}
+
+const Exiv2Value*
+exiv2_iptc_datum_value(const Exiv2IptcDatum *datum)
+{
+	return &datum->value;
+}
+
+const Exiv2Value*
+exiv2_exif_datum_value(const Exiv2ExifDatum *datum)
+{
+	return &datum->value;
+}
+
+int
+exiv2_value_type_id(const Exiv2Value *v)
+{
+	if (v->value == 0) {
+		return Exiv2::invalidTypeId;
+	}
+	return v->value->typeId();
+}
+
+long
+exiv2_value_count(const Exiv2Value *v)
+{
+	if (v->value == 0) {
+		return 0;
+	}
+	return v->value->count();
+}
+
+long
+exiv2_value_size(const Exiv2Value *v)
+{
+	if (v->value == 0) {
+		return 0;
+	}
+	return v->value->size();
+}
+
+// checkValueIndex throws if component n of the value can't be accessed.
+static void
+checkValueIndex(const Exiv2Value *v, long n)
+{
+	if (v->value == 0) {
+		throw Exiv2::Error(Exiv2::kerValueNotSet);
+	}
+	if (n < 0 || n >= v->value->count()) {
+		throw Exiv2::Error(Exiv2::kerErrorMessage, std::string("value index out of range"));
+	}
+}
+
+long
+exiv2_value_to_long(const Exiv2Value *v, long n, Exiv2Error **error)
+{
+	try {
+		checkValueIndex(v, n);
+
+		long l = v->value->toLong(n);
+		if (!v->value->ok()) {
+			throw Exiv2::Error(Exiv2::kerErrorMessage, std::string("cannot convert value to an integer"));
+		}
+		return l;
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return 0;
+}
+
+double
+exiv2_value_to_double(const Exiv2Value *v, long n, Exiv2Error **error)
+{
+	try {
+		checkValueIndex(v, n);
+
+		// toFloat() goes through a float, read doubles and rationals
+		// directly to keep their precision
+		const Exiv2::DoubleValue *dv = dynamic_cast<const Exiv2::DoubleValue*>(v->value);
+		if (dv) {
+			return dv->value_.at(n);
+		}
+
+		Exiv2::TypeId typeId = v->value->typeId();
+		if (typeId == Exiv2::unsignedRational || typeId == Exiv2::signedRational) {
+			long num = 0, den = 0;
+			exiv2_value_to_rational(v, n, &num, &den, 0);
+			if (den == 0) {
+				throw Exiv2::Error(Exiv2::kerErrorMessage, std::string("rational value has a zero denominator"));
+			}
+			return (double)num / (double)den;
+		}
+
+		float f = v->value->toFloat(n);
+		if (!v->value->ok()) {
+			throw Exiv2::Error(Exiv2::kerErrorMessage, std::string("cannot convert value to a float"));
+		}
+		return f;
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return 0;
+}
+
+void
+exiv2_value_to_rational(const Exiv2Value *v, long n, long *num, long *den, Exiv2Error **error)
+{
+	try {
+		checkValueIndex(v, n);
+
+		// toRational() returns signed 32 bit integers, which unsigned
+		// rationals may overflow
+		const Exiv2::URationalValue *urv = dynamic_cast<const Exiv2::URationalValue*>(v->value);
+		if (urv) {
+			*num = urv->value_.at(n).first;
+			*den = urv->value_.at(n).second;
+			return;
+		}
+
+		Exiv2::Rational r = v->value->toRational(n);
+		if (!v->value->ok()) {
+			throw Exiv2::Error(Exiv2::kerErrorMessage, std::string("cannot convert value to a rational"));
+		}
+		*num = r.first;
+		*den = r.second;
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+long
+exiv2_value_copy(const Exiv2Value *v, unsigned char *buf)
+{
+	if (v->value == 0) {
+		return 0;
+	}
+	return v->value->copy(buf, Exiv2::littleEndian);
+}
+
+char*
+exiv2_value_to_string(const Exiv2Value *v)
+{
+	if (v->value == 0) {
+		return strdup("");
+	}
+	return strdup(v->value->toString().c_str());
+}
+
+char*
+exiv2_value_to_string_n(const Exiv2Value *v, long n, Exiv2Error **error)
+{
+	try {
+		checkValueIndex(v, n);
+		return strdup(v->value->toString(n).c_str());
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return 0;
+}
+
+int
+exiv2_exif_key_default_type_id(const char *key, Exiv2Error **error)
+{
+	try {
+		return Exiv2::ExifKey(key).defaultTypeId();
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return Exiv2::invalidTypeId;
+}
+
+int
+exiv2_iptc_key_default_type_id(const char *key, Exiv2Error **error)
+{
+	try {
+		Exiv2::IptcKey iptcKey(key);
+		return Exiv2::IptcDataSets::dataSetType(iptcKey.tag(), iptcKey.record());
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return Exiv2::invalidTypeId;
+}
+
+int
+exiv2_xmp_key_default_type_id(const char *key, Exiv2Error **error)
+{
+	try {
+		return Exiv2::XmpProperties::propertyType(Exiv2::XmpKey(key));
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+
+	return Exiv2::invalidTypeId;
+}
+
+void
+exiv2_value_lang_alt_at(const Exiv2Value *v, long n, char **lang, char **text, Exiv2Error **error)
+{
+	try {
+		checkValueIndex(v, n);
+
+		const Exiv2::LangAltValue *langAlt = dynamic_cast<const Exiv2::LangAltValue*>(v->value);
+		if (!langAlt) {
+			throw Exiv2::Error(Exiv2::kerErrorMessage, std::string("value is not a language alternative"));
+		}
+
+		Exiv2::LangAltValue::ValueType::const_iterator it = langAlt->value_.begin();
+		std::advance(it, n);
+		*lang = strdup(it->first.c_str());
+		*text = strdup(it->second.c_str());
+	} catch (Exiv2::Error &e) {
+		if (error) {
+			*error = new Exiv2Error(e);
+		}
+	}
+}
+
+const char*
+exiv2_type_name(int typeId)
+{
+	return Exiv2::TypeInfo::typeName(static_cast<Exiv2::TypeId>(typeId));
+}
+
 // LOG LEVEL
 
 void
@@ -1,3 +1,5 @@
+#include <stdint.h>
+
 #ifdef __cplusplus
 extern "C" {
 #endif
@@ -15,24 +17,37 @@ DECLARE_STRUCT(Exiv2IptcDatumIterator);
 DECLARE_STRUCT(Exiv2ExifData);
 DECLARE_STRUCT(Exiv2ExifDatum);
 DECLARE_STRUCT(Exiv2ExifDatumIterator);
+DECLARE_STRUCT(Exiv2Value);
+DECLARE_STRUCT(Exiv2Editor);
 DECLARE_STRUCT(Exiv2Error);
 
+typedef struct {
+	char *mime_type;
+	char *extension;
+	long size;
+	int width;
+	int height;
+	int id;
+} Exiv2PreviewProperties;
+
 void exiv2_xmp_datum_iterator_free(Exiv2XmpDatumIterator *datum);
 void exiv2_iptc_datum_iterator_free(Exiv2IptcDatumIterator *datum);
 void exiv2_exif_datum_iterator_free(Exiv2ExifDatumIterator *datum);
 
 Exiv2Image* exiv2_image_factory_open(const char *path, Exiv2Error **error);
 Exiv2Image* exiv2_image_factory_open_bytes(const unsigned char *path, long size, Exiv2Error **error);
+Exiv2Image* exiv2_image_factory_open_reader(uintptr_t handle, long size, Exiv2Error **error);
+Exiv2Image* exiv2_image_factory_open_sidecar(const char *path, Exiv2Error **error);
+Exiv2Image* exiv2_image_factory_new_sidecar(Exiv2Error **error);
+void exiv2_image_write_sidecar(Exiv2Image *img, const char *path, Exiv2Error **error);
 
 long exiv_image_get_size(Exiv2Image *img);
 unsigned char* exiv_image_get_bytes_ptr(Exiv2Image *img);
+void exiv2_image_io_open(Exiv2Image *img, Exiv2Error **error);
+long exiv2_image_io_read(Exiv2Image *img, unsigned char *buf, long size, Exiv2Error **error);
+void exiv2_image_io_close(Exiv2Image *img);
 
 void exiv2_image_read_metadata(Exiv2Image *img, Exiv2Error **error);
-void exiv2_image_set_exif_string(Exiv2Image *img, char *key, char *value, Exiv2Error **error);
-void exiv2_image_set_exif_short(Exiv2Image *img, char *key, char *value, Exiv2Error **error);
-void exiv2_image_set_iptc_string(Exiv2Image *img, char *key, char *value, Exiv2Error **error);
-void exiv2_image_set_xmp_string(Exiv2Image *img, char *key, char *value, Exiv2Error **error);
-void exiv2_image_set_iptc_short(Exiv2Image *img, char *key, char *value, Exiv2Error **error);
 void exiv2_image_free(Exiv2Image *img);
 
 int exiv2_image_get_pixel_width(Exiv2Image *img);
@@ -48,6 +63,15 @@ Exiv2XmpDatumIterator* exiv2_xmp_data_iterator(const Exiv2XmpData *data);
 int exiv2_xmp_data_iterator_has_next(const Exiv2XmpDatumIterator *iter);
 Exiv2XmpDatum* exiv2_xmp_datum_iterator_next(Exiv2XmpDatumIterator *iter);
 
+void exiv2_xmp_register_ns(const char *uri, const char *prefix, Exiv2Error **error);
+void exiv2_xmp_unregister_ns(const char *prefix, Exiv2Error **error);
+char* exiv2_xmp_ns(const char *prefix);
+int exiv2_xmp_namespaces(char ***prefixes, char ***uris);
+
+char* exiv2_image_xmp_packet(const Exiv2Image *img, long *size);
+char* exiv2_xmp_data_serialize(const Exiv2XmpData *data, unsigned int flags, unsigned int padding, long *size, Exiv2Error **error);
+Exiv2Image* exiv2_xmp_parse(const char *packet, long size, Exiv2Error **error);
+
 Exiv2IptcData* exiv2_image_get_iptc_data(const Exiv2Image *img);
 void exiv2_iptc_data_free(Exiv2IptcData *data);
 const char* exiv2_iptc_datum_key(const Exiv2IptcDatum *datum);
@@ -61,6 +85,8 @@ Exiv2IptcDatum* exiv2_iptc_datum_iterator_next(Exiv2IptcDatumIterator *iter);
 Exiv2ExifData* exiv2_image_get_exif_data(const Exiv2Image *img);
 const char* exiv2_exif_datum_key(const Exiv2ExifDatum *datum);
 const char* exiv2_exif_datum_to_string(const Exiv2ExifDatum *datum);
+const char* exiv2_exif_datum_print(const Exiv2ExifDatum *datum, const Exiv2ExifData *data);
+Exiv2ExifDatum* exiv2_exif_data_easy_access(const Exiv2ExifData *data, int field, Exiv2Error **error);
 void exiv2_exif_datum_free(Exiv2ExifDatum *datum);
 void exiv2_exif_data_free(Exiv2ExifData *data);
 Exiv2ExifDatum* exiv2_exif_data_find_key(const Exiv2ExifData *data, const char *key, Exiv2Error **error);
@@ -68,19 +94,86 @@ Exiv2ExifDatumIterator* exiv2_exif_data_iterator(const Exiv2ExifData *data);
 int exiv2_exif_data_iterator_has_next(const Exiv2ExifDatumIterator *iter);
 Exiv2ExifDatum* exiv2_exif_datum_iterator_next(Exiv2ExifDatumIterator *iter);
 
-void exiv2_exif_strip_key(Exiv2Image *img, char *key, Exiv2Error **error);
-void exiv2_iptc_strip_key(Exiv2Image *img, char *key, Exiv2Error **error);
-void exiv2_xmp_strip_key(Exiv2Image *img, char *key, Exiv2Error **error);
+const Exiv2Value* exiv2_xmp_datum_value(const Exiv2XmpDatum *datum);
+const Exiv2Value* exiv2_iptc_datum_value(const Exiv2IptcDatum *datum);
+const Exiv2Value* exiv2_exif_datum_value(const Exiv2ExifDatum *datum);
+int exiv2_value_type_id(const Exiv2Value *v);
+long exiv2_value_count(const Exiv2Value *v);
+long exiv2_value_size(const Exiv2Value *v);
+long exiv2_value_to_long(const Exiv2Value *v, long n, Exiv2Error **error);
+double exiv2_value_to_double(const Exiv2Value *v, long n, Exiv2Error **error);
+void exiv2_value_to_rational(const Exiv2Value *v, long n, long *num, long *den, Exiv2Error **error);
+long exiv2_value_copy(const Exiv2Value *v, unsigned char *buf);
+char* exiv2_value_to_string(const Exiv2Value *v);
+char* exiv2_value_to_string_n(const Exiv2Value *v, long n, Exiv2Error **error);
+void exiv2_value_lang_alt_at(const Exiv2Value *v, long n, char **lang, char **text, Exiv2Error **error);
+const char* exiv2_type_name(int typeId);
+int exiv2_exif_key_default_type_id(const char *key, Exiv2Error **error);
+int exiv2_iptc_key_default_type_id(const char *key, Exiv2Error **error);
+int exiv2_xmp_key_default_type_id(const char *key, Exiv2Error **error);
 
 void exiv2_exif_strip_data(Exiv2Image *img, char **keysToRemove, int len, Exiv2Error **error);
 void exiv2_iptc_strip_data(Exiv2Image *img, char **keysToRemove, int len, Exiv2Error **error);
 void exiv2_xmp_strip_data(Exiv2Image *img, char **keysToRemove, int len, Exiv2Error **error);
 
+Exiv2Editor* exiv2_image_editor(Exiv2Image *img);
+void exiv2_editor_set_exif_string(Exiv2Editor *ed, char *key, char *value, Exiv2Error **error);
+void exiv2_editor_set_exif_short(Exiv2Editor *ed, char *key, char *value, Exiv2Error **error);
+void exiv2_editor_set_iptc_string(Exiv2Editor *ed, char *key, char *value, Exiv2Error **error);
+void exiv2_editor_set_iptc_short(Exiv2Editor *ed, char *key, char *value, Exiv2Error **error);
+void exiv2_editor_set_xmp_string(Exiv2Editor *ed, char *key, char *value, Exiv2Error **error);
+void exiv2_editor_set_exif_value(Exiv2Editor *ed, char *key, int typeId, char *text, unsigned char *data, long size, Exiv2Error **error);
+void exiv2_editor_set_iptc_value(Exiv2Editor *ed, char *key, int typeId, char *text, unsigned char *data, long size, Exiv2Error **error);
+void exiv2_editor_set_xmp_value(Exiv2Editor *ed, char *key, int typeId, char *text, unsigned char *data, long size, Exiv2Error **error);
+void exiv2_editor_add_iptc_string(Exiv2Editor *ed, char *key, char *value, Exiv2Error **error);
+void exiv2_editor_iptc_strip_all(Exiv2Editor *ed, char *key, Exiv2Error **error);
+void exiv2_editor_set_xmp_array(Exiv2Editor *ed, char *key, int typeId, char **values, int len, Exiv2Error **error);
+void exiv2_editor_set_xmp_lang_alt(Exiv2Editor *ed, char *key, char *lang, char *value, Exiv2Error **error);
+void exiv2_editor_set_xmp_struct(Exiv2Editor *ed, char *key, Exiv2Error **error);
+void exiv2_editor_set_xmp_packet(Exiv2Editor *ed, const char *packet, long size, Exiv2Error **error);
+void exiv2_editor_merge_xmp_sidecar(Exiv2Editor *ed, const char *path, Exiv2Error **error);
+void exiv2_editor_sync_exif_to_xmp(Exiv2Editor *ed, int overwrite, Exiv2Error **error);
+void exiv2_editor_sync_iptc_to_xmp(Exiv2Editor *ed, const char *charset, int overwrite, Exiv2Error **error);
+void exiv2_editor_sync_xmp_to_exif(Exiv2Editor *ed, int overwrite, Exiv2Error **error);
+void exiv2_editor_sync_xmp_to_iptc(Exiv2Editor *ed, int overwrite, Exiv2Error **error);
+void exiv2_editor_copy_exif(Exiv2Editor *ed, const Exiv2Image *src, char **include, int includeLen, char **exclude, int excludeLen, Exiv2Error **error);
+void exiv2_editor_copy_iptc(Exiv2Editor *ed, const Exiv2Image *src, char **include, int includeLen, char **exclude, int excludeLen, Exiv2Error **error);
+void exiv2_editor_copy_xmp(Exiv2Editor *ed, const Exiv2Image *src, char **include, int includeLen, char **exclude, int excludeLen, Exiv2Error **error);
+void exiv2_editor_fix_dimensions(Exiv2Editor *ed, Exiv2Error **error);
+void exiv2_editor_set_icc_profile(Exiv2Editor *ed, const unsigned char *profile, long size);
+void exiv2_editor_set_comment(Exiv2Editor *ed, const char *comment);
+void exiv2_editor_exif_strip_prefix(Exiv2Editor *ed, char *prefix);
+void exiv2_editor_iptc_strip_prefix(Exiv2Editor *ed, char *prefix);
+void exiv2_editor_xmp_strip_prefix(Exiv2Editor *ed, char *prefix);
+void exiv2_editor_exif_strip_key(Exiv2Editor *ed, char *key, Exiv2Error **error);
+void exiv2_editor_iptc_strip_key(Exiv2Editor *ed, char *key, Exiv2Error **error);
+void exiv2_editor_xmp_strip_key(Exiv2Editor *ed, char *key, Exiv2Error **error);
+void exiv2_editor_commit(Exiv2Editor *ed, Exiv2Error **error);
+void exiv2_editor_free(Exiv2Editor *ed);
+
 const unsigned char* exiv2_image_icc_profile(Exiv2Image *img);
 long exiv2_image_icc_profile_size(Exiv2Image *img);
 
+char* exiv2_image_comment(const Exiv2Image *img);
+
+int exiv2_image_type(const Exiv2Image *img);
+int exiv2_detect_type(const unsigned char *data, long size);
+char* exiv2_image_mime_type(const Exiv2Image *img);
+int exiv2_image_check_mode(const Exiv2Image *img, int metadataId);
+
+unsigned char* exiv2_image_exif_thumbnail(const Exiv2Image *img, char **mime, long *size, Exiv2Error **error);
+void exiv2_editor_set_exif_thumbnail(Exiv2Editor *ed, const unsigned char *data, long size, Exiv2Error **error);
+void exiv2_editor_remove_exif_thumbnail(Exiv2Editor *ed, Exiv2Error **error);
+
+Exiv2PreviewProperties* exiv2_image_previews(const Exiv2Image *img, int *len, Exiv2Error **error);
+void exiv2_preview_properties_free(Exiv2PreviewProperties *props, int len);
+unsigned char* exiv2_image_preview(const Exiv2Image *img, int id, long *size, Exiv2Error **error);
+
 void exiv2_log_msg_set_level(const int level);
 
+// Implemented in Go, see reader.go.
+long goexivReadAt(uintptr_t handle, unsigned char *buf, long n, long off);
+
 int exiv2_error_code(const Exiv2Error *e);
 const char *exiv2_error_what(const Exiv2Error *e);
 void exiv2_error_free(Exiv2Error *e);
@@ -0,0 +1,168 @@
+package goexiv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// iccHeaderSize is the size of the fixed ICC profile header, which is
+// followed by the tag table.
+const iccHeaderSize = 128
+
+// ICCProfileInfo describes an ICC profile, as read from its header and its
+// description tag.
+type ICCProfileInfo struct {
+	// Version is the profile version, e.g. "4.3.0".
+	Version string
+	// DeviceClass is the profile class signature, e.g. "mntr".
+	DeviceClass string
+	// ColorSpace is the data color space signature, e.g. "RGB".
+	ColorSpace string
+	// Description is the profile description, e.g. "sRGB IEC61966-2.1".
+	Description string
+}
+
+// ParseICCProfile validates the header of an ICC profile and returns its
+// information.
+func ParseICCProfile(profile []byte) (*ICCProfileInfo, error) {
+	if len(profile) < iccHeaderSize {
+		return nil, errors.New("ICC profile is too short")
+	}
+	if size := binary.BigEndian.Uint32(profile[0:4]); int64(size) != int64(len(profile)) {
+		return nil, fmt.Errorf("ICC profile size %d doesn't match its length %d", size, len(profile))
+	}
+	if string(profile[36:40]) != "acsp" {
+		return nil, errors.New("ICC profile signature not found")
+	}
+
+	return &ICCProfileInfo{
+		Version:     fmt.Sprintf("%d.%d.%d", profile[8], profile[9]>>4, profile[9]&0x0f),
+		DeviceClass: strings.TrimSpace(string(profile[12:16])),
+		ColorSpace:  strings.TrimSpace(string(profile[16:20])),
+		Description: iccDescription(profile),
+	}, nil
+}
+
+// iccDescription returns the text of the 'desc' tag of a profile, or an
+// empty string if it can't be read.
+func iccDescription(profile []byte) string {
+	tag := iccTag(profile, "desc")
+	if len(tag) < 12 {
+		return ""
+	}
+
+	switch string(tag[0:4]) {
+	case "desc":
+		// textDescriptionType of ICC v2: a counted ASCII string
+		n := binary.BigEndian.Uint32(tag[8:12])
+		if n == 0 || uint64(n) > uint64(len(tag)-12) {
+			return ""
+		}
+		return strings.TrimRight(string(tag[12:12+n]), "\x00")
+	case "mluc":
+		// multiLocalizedUnicodeType of ICC v4: the first record is used
+		if len(tag) < 28 || binary.BigEndian.Uint32(tag[8:12]) == 0 {
+			return ""
+		}
+		length := uint64(binary.BigEndian.Uint32(tag[20:24]))
+		offset := uint64(binary.BigEndian.Uint32(tag[24:28]))
+		if offset+length > uint64(len(tag)) {
+			return ""
+		}
+		text := tag[offset : offset+length]
+		units := make([]uint16, len(text)/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(text[2*i:])
+		}
+		return string(utf16.Decode(units))
+	}
+
+	return ""
+}
+
+// iccTag returns the data of a tag of a profile, or nil if the profile
+// doesn't have it.
+func iccTag(profile []byte, signature string) []byte {
+	if len(profile) < iccHeaderSize+4 {
+		return nil
+	}
+
+	count := uint64(binary.BigEndian.Uint32(profile[iccHeaderSize:]))
+	for i := uint64(0); i < count; i++ {
+		entry := iccHeaderSize + 4 + 12*i
+		if entry+12 > uint64(len(profile)) {
+			return nil
+		}
+		if string(profile[entry:entry+4]) != signature {
+			continue
+		}
+
+		offset := uint64(binary.BigEndian.Uint32(profile[entry+4:]))
+		size := uint64(binary.BigEndian.Uint32(profile[entry+8:]))
+		if offset+size > uint64(len(profile)) {
+			return nil
+		}
+		return profile[offset : offset+size]
+	}
+
+	return nil
+}
+
+// ICCProfileInfo returns the information of the ICC profile of the image,
+// or nil if the image doesn't have one.
+func (i *Image) ICCProfileInfo() (*ICCProfileInfo, error) {
+	if i.closed() {
+		return nil, ErrImageClosed
+	}
+
+	profile := i.ICCProfile()
+	if profile == nil {
+		return nil, nil
+	}
+
+	return ParseICCProfile(profile)
+}
+
+// SetICCProfile embeds an ICC profile in the image, replacing the existing
+// one. The profile header is validated first.
+func (i *Image) SetICCProfile(profile []byte) error {
+	return i.edit(func(e *Editor) error {
+		return e.SetICCProfile(profile)
+	})
+}
+
+// ClearICCProfile removes the ICC profile of the image.
+func (i *Image) ClearICCProfile() error {
+	return i.edit(func(e *Editor) error {
+		return e.ClearICCProfile()
+	})
+}
+
+// SetICCProfile embeds an ICC profile in the image, replacing the existing
+// one. The profile header is validated first.
+func (e *Editor) SetICCProfile(profile []byte) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	if _, err := ParseICCProfile(profile); err != nil {
+		return err
+	}
+
+	e.setICCProfile(profile)
+
+	return nil
+}
+
+// ClearICCProfile removes the ICC profile of the image.
+func (e *Editor) ClearICCProfile() error {
+	if err := e.check(); err != nil {
+		return err
+	}
+
+	e.setICCProfile(nil)
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package goexiv
+
+// #cgo pkg-config: exiv2
+// #include "helper.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// ImageType is the file format of an image. TIFF based RAW formats like DNG
+// or NEF are reported as ImageTypeTIFF.
+type ImageType int
+
+const (
+	ImageTypeUnknown ImageType = iota
+	ImageTypeJPEG
+	ImageTypeEXV
+	ImageTypeCR2
+	ImageTypeCRW
+	ImageTypeTIFF
+	ImageTypeMRW
+	ImageTypePNG
+	ImageTypeRAF
+	ImageTypeORF
+	ImageTypeXMP
+	ImageTypeGIF
+	ImageTypePSD
+	ImageTypeTGA
+	ImageTypeBMP
+	ImageTypeJP2
+	ImageTypeRW2
+	ImageTypePGF
+	ImageTypeWebP
+	ImageTypeEPS
+)
+
+var imageTypeNames = [...]string{
+	"unknown", "jpeg", "exv", "cr2", "crw", "tiff", "mrw", "png", "raf", "orf",
+	"xmp", "gif", "psd", "tga", "bmp", "jp2", "rw2", "pgf", "webp", "eps",
+}
+
+func (t ImageType) String() string {
+	if t < 0 || int(t) >= len(imageTypeNames) {
+		return fmt.Sprintf("ImageType(%d)", int(t))
+	}
+	return imageTypeNames[t]
+}
+
+// AccessMode mirrors Exiv2::AccessMode, the support of an image format for
+// a metadata family.
+type AccessMode int
+
+const (
+	AccessNone      AccessMode = 0
+	AccessRead      AccessMode = 1
+	AccessWrite     AccessMode = 2
+	AccessReadWrite AccessMode = 3
+)
+
+// CanRead reports whether the metadata can be read.
+func (m AccessMode) CanRead() bool {
+	return m&AccessRead != 0
+}
+
+// CanWrite reports whether the metadata can be written.
+func (m AccessMode) CanWrite() bool {
+	return m&AccessWrite != 0
+}
+
+// Exiv2::MetadataId values of the metadata families.
+const (
+	mdExif    = 1
+	mdIptc    = 2
+	mdComment = 4
+	mdXmp     = 8
+)
+
+// DetectType returns the format of image data from its first bytes,
+// without opening the image.
+func DetectType(data []byte) ImageType {
+	if len(data) == 0 {
+		return ImageTypeUnknown
+	}
+
+	return ImageType(C.exiv2_detect_type((*C.uchar)(unsafe.Pointer(&data[0])), C.long(len(data))))
+}
+
+// Type returns the format of the image.
+func (i *Image) Type() ImageType {
+	if i.closed() {
+		return ImageTypeUnknown
+	}
+	defer runtime.KeepAlive(i)
+
+	return ImageType(C.exiv2_image_type(i.img))
+}
+
+// MimeType returns the MIME type of the image, e.g. "image/jpeg".
+func (i *Image) MimeType() string {
+	if i.closed() {
+		return ""
+	}
+	defer runtime.KeepAlive(i)
+
+	cstr := C.exiv2_image_mime_type(i.img)
+	defer C.free(unsafe.Pointer(cstr))
+
+	return C.GoString(cstr)
+}
+
+// Supports returns whether the format of the image supports reading and
+// writing a metadata family.
+func (i *Image) Supports(f MetadataFormat) AccessMode {
+	if i.closed() {
+		return AccessNone
+	}
+	defer runtime.KeepAlive(i)
+
+	var id C.int
+	switch f {
+	case EXIF:
+		id = mdExif
+	case IPTC:
+		id = mdIptc
+	case XMP:
+		id = mdXmp
+	default:
+		return AccessNone
+	}
+
+	return AccessMode(C.exiv2_image_check_mode(i.img, id))
+}
@@ -33,20 +33,39 @@ func makeIptcData(img *Image, cdata *C.Exiv2IptcData) *IptcData {
 	}
 
 	runtime.SetFinalizer(data, func(x *IptcData) {
-		C.exiv2_iptc_data_free(x.data)
+		x.Close()
 	})
 
 	return data
 }
 
+// Close frees the underlying C++ structure. Calling Close more than once is
+// a no-op.
+func (d *IptcData) Close() error {
+	if d.data == nil {
+		return nil
+	}
+
+	C.exiv2_iptc_data_free(d.data)
+	d.data = nil
+	runtime.SetFinalizer(d, nil)
+
+	return nil
+}
+
+// closed reports whether the data or the image it belongs to has been closed.
+func (d *IptcData) closed() bool {
+	return d.data == nil || d.img.closed()
+}
+
 func makeIptcDatum(data *IptcData, cdatum *C.Exiv2IptcDatum) *IptcDatum {
 	if cdatum == nil {
 		return nil
 	}
 
 	datum := &IptcDatum{
-		data,
-		cdatum,
+		data:  data,
+		datum: cdatum,
 	}
 
 	runtime.SetFinalizer(datum, func(x *IptcDatum) {
@@ -57,6 +76,11 @@ func makeIptcDatum(data *IptcData, cdatum *C.Exiv2IptcDatum) *IptcDatum {
 }
 
 func (i *Image) GetIptcData() *IptcData {
+	if i.closed() {
+		return &IptcData{img: i}
+	}
+	defer runtime.KeepAlive(i)
+
 	return makeIptcData(i, C.exiv2_image_get_iptc_data(i.img))
 }
 
@@ -68,6 +92,35 @@ func (i *Image) SetIptcShort(key, value string) error {
 	return i.SetMetadataShort(IPTC, key, value)
 }
 
+// AddIptcString adds a new entry to an IPTC dataset, keeping the existing
+// ones. It returns an error if the dataset already has an entry and isn't
+// repeatable.
+func (i *Image) AddIptcString(key, value string) error {
+	return i.edit(func(e *Editor) error {
+		return e.AddIptcString(key, value)
+	})
+}
+
+// SetIptcStrings replaces every entry of an IPTC dataset with one entry per
+// value, e.g. to write the full list of Iptc.Application2.Keywords.
+func (i *Image) SetIptcStrings(key string, values []string) error {
+	return i.edit(func(e *Editor) error {
+		return e.SetIptcStrings(key, values)
+	})
+}
+
+// DeleteIptcAll removes every entry of an IPTC dataset.
+func (i *Image) DeleteIptcAll(key string) error {
+	return i.edit(func(e *Editor) error {
+		return e.DeleteIptcAll(key)
+	})
+}
+
+// SetIptcValue sets an IPTC key with a given typed value.
+func (i *Image) SetIptcValue(key string, v Value) error {
+	return i.SetValue(IPTC, key, v)
+}
+
 func (d *IptcData) GetString(key string) (string, error) {
 	datum, err := d.FindKey(key)
 	if err != nil {
@@ -82,6 +135,11 @@ func (d *IptcData) GetString(key string) (string, error) {
 }
 
 func (d *IptcData) FindKey(key string) (*IptcDatum, error) {
+	if d.closed() {
+		return nil, ErrImageClosed
+	}
+	defer runtime.KeepAlive(d)
+
 	ckey := C.CString(key)
 	defer C.free(unsafe.Pointer(ckey))
 
@@ -100,39 +158,180 @@ func (d *IptcData) FindKey(key string) (*IptcDatum, error) {
 
 // Key returns the IPTC key of the datum.
 func (d *IptcDatum) Key() string {
-	return C.GoString(C.exiv2_iptc_datum_key(d.datum))
+	if d.data.img.closed() {
+		return ""
+	}
+	defer runtime.KeepAlive(d)
+
+	cstr := C.exiv2_iptc_datum_key(d.datum)
+	defer C.free(unsafe.Pointer(cstr))
+
+	return C.GoString(cstr)
 }
 
 func (d *IptcDatum) String() string {
+	if d.data.img.closed() {
+		return ""
+	}
+	defer runtime.KeepAlive(d)
+
 	cstr := C.exiv2_iptc_datum_to_string(d.datum)
 	defer C.free(unsafe.Pointer(cstr))
 
 	return C.GoString(cstr)
 }
 
+// value returns the value of the datum.
+func (d *IptcDatum) value() *datumValue {
+	if d.data.img.closed() {
+		return &datumValue{img: d.data.img}
+	}
+
+	return &datumValue{
+		img:   d.data.img,
+		val:   C.exiv2_iptc_datum_value(d.datum),
+		owner: d,
+	}
+}
+
+// TypeID returns the type of the datum value.
+func (d *IptcDatum) TypeID() TypeID {
+	return d.value().typeID()
+}
+
+// Count returns the number of components of the datum value.
+func (d *IptcDatum) Count() int {
+	return d.value().count()
+}
+
+// Size returns the size of the datum value in bytes.
+func (d *IptcDatum) Size() int {
+	return d.value().size()
+}
+
+// Int64 returns the n-th component of the datum value as an integer.
+func (d *IptcDatum) Int64(n int) (int64, error) {
+	return d.value().int64(n)
+}
+
+// Float64 returns the n-th component of the datum value as a float.
+func (d *IptcDatum) Float64(n int) (float64, error) {
+	return d.value().float64(n)
+}
+
+// Rational returns the n-th component of the datum value as a rational.
+func (d *IptcDatum) Rational(n int) (Rational, error) {
+	return d.value().rational(n)
+}
+
+// Bytes returns the raw data of the datum value. Multi-byte numbers are
+// encoded in little-endian byte order.
+func (d *IptcDatum) Bytes() []byte {
+	return d.value().bytes()
+}
+
+// Values returns every component of the datum value as a string. Text values
+// are returned as a single element.
+func (d *IptcDatum) Values() ([]string, error) {
+	return d.value().values()
+}
+
 // AllTags returns all IPTC tags
 func (d *IptcData) AllTags() map[string]string {
 	keyValues := map[string]string{}
-	for i := d.Iterator(); i.HasNext(); {
-		d := i.Next()
+	iter := d.Iterator()
+	defer iter.Close()
+
+	for iter.HasNext() {
+		d := iter.Next()
 		keyValues[d.Key()] = d.String()
 	}
 
 	return keyValues
 }
 
+// AllEntries returns all IPTC entries in the order they are stored, keeping
+// every entry of keys that occur more than once.
+func (d *IptcData) AllEntries() []Entry {
+	var entries []Entry
+	indexes := map[string]int{}
+
+	iter := d.Iterator()
+	defer iter.Close()
+
+	for iter.HasNext() {
+		datum := iter.Next()
+		key := datum.Key()
+
+		entries = append(entries, Entry{
+			Key:    key,
+			Value:  datum.String(),
+			TypeID: datum.TypeID(),
+			Index:  indexes[key],
+		})
+		indexes[key]++
+	}
+
+	return entries
+}
+
+// GetAll returns the values of every entry with the given key, in the order
+// they are stored.
+func (d *IptcData) GetAll(key string) ([]string, error) {
+	datum, err := d.FindKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if datum == nil {
+		return nil, ErrMetadataKeyNotFound
+	}
+
+	// FindKey has validated the key, now use its canonical form
+	key = datum.Key()
+
+	var values []string
+
+	iter := d.Iterator()
+	defer iter.Close()
+
+	for iter.HasNext() {
+		datum := iter.Next()
+		if datum.Key() == key {
+			values = append(values, datum.String())
+		}
+	}
+
+	return values, nil
+}
+
 // Iterator returns a new IptcDatumIterator to iterate over all IPTC data.
 func (d *IptcData) Iterator() *IptcDatumIterator {
+	if d.closed() {
+		return &IptcDatumIterator{data: d}
+	}
+	defer runtime.KeepAlive(d)
+
 	return makeIptcDatumIterator(d, C.exiv2_iptc_data_iterator(d.data))
 }
 
 // HasNext returns true as long as the iterator has another datum to deliver.
 func (i *IptcDatumIterator) HasNext() bool {
+	if i.closed() {
+		return false
+	}
+	defer runtime.KeepAlive(i)
+
 	return C.exiv2_iptc_data_iterator_has_next(i.iter) != 0
 }
 
 // Next returns the next IptcDatum of the iterator or nil if iterator has reached the end.
 func (i *IptcDatumIterator) Next() *IptcDatum {
+	if i.closed() {
+		return nil
+	}
+	defer runtime.KeepAlive(i)
+
 	return makeIptcDatum(i.data, C.exiv2_iptc_datum_iterator_next(i.iter))
 }
 
@@ -141,12 +340,32 @@ func makeIptcDatumIterator(data *IptcData, cIter *C.Exiv2IptcDatumIterator) *Ipt
 	datum := &IptcDatumIterator{data, cIter}
 
 	runtime.SetFinalizer(datum, func(i *IptcDatumIterator) {
-		C.exiv2_iptc_datum_iterator_free(i.iter)
+		i.Close()
 	})
 
 	return datum
 }
 
+// Close frees the underlying C++ iterator. Calling Close more than once is a
+// no-op.
+func (i *IptcDatumIterator) Close() error {
+	if i.iter == nil {
+		return nil
+	}
+
+	C.exiv2_iptc_datum_iterator_free(i.iter)
+	i.iter = nil
+	runtime.SetFinalizer(i, nil)
+
+	return nil
+}
+
+// closed reports whether the iterator or the image it belongs to has been
+// closed.
+func (i *IptcDatumIterator) closed() bool {
+	return i.iter == nil || i.data.img.closed()
+}
+
 // IptcStripKey removes the given key from the IPTC metadata.
 func (i *Image) IptcStripKey(key string) error {
 	return i.StripKey(IPTC, key)
@@ -154,9 +373,17 @@ func (i *Image) IptcStripKey(key string) error {
 
 // IptcStripMetadata removes all EXIF metadata except the keys in the unless array.
 func (i *Image) IptcStripMetadata(unless []string) error {
+	if i.closed() {
+		return ErrImageClosed
+	}
+	defer runtime.KeepAlive(i)
+
 	var cErr *C.Exiv2Error
 
-	tagsToRemove := getKeysToRemove(i.GetIptcData(), unless)
+	data := i.GetIptcData()
+	defer data.Close()
+
+	tagsToRemove := getKeysToRemove(data, unless)
 	if len(tagsToRemove) == 0 {
 		return nil
 	}
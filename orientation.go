@@ -0,0 +1,228 @@
+package goexiv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Orientation is the value of the EXIF Orientation tag, the transformation
+// to apply to the stored pixels to display the image upright.
+type Orientation int
+
+const (
+	OrientationNormal Orientation = iota + 1
+	OrientationMirrorHorizontal
+	OrientationRotate180
+	OrientationMirrorVertical
+	OrientationMirrorHorizontalRotate270
+	OrientationRotate90
+	OrientationMirrorHorizontalRotate90
+	OrientationRotate270
+)
+
+var orientationNames = [...]string{
+	"normal", "mirror horizontal", "rotate 180", "mirror vertical",
+	"mirror horizontal and rotate 270 CW", "rotate 90 CW",
+	"mirror horizontal and rotate 90 CW", "rotate 270 CW",
+}
+
+func (o Orientation) String() string {
+	if !o.valid() {
+		return fmt.Sprintf("Orientation(%d)", int(o))
+	}
+	return orientationNames[o-1]
+}
+
+// Rotation returns the clockwise rotation in degrees to apply to the stored
+// pixels, after mirroring them if Mirrored returns true.
+func (o Orientation) Rotation() int {
+	switch o {
+	case OrientationRotate180, OrientationMirrorVertical:
+		return 180
+	case OrientationRotate90, OrientationMirrorHorizontalRotate90:
+		return 90
+	case OrientationRotate270, OrientationMirrorHorizontalRotate270:
+		return 270
+	}
+	return 0
+}
+
+// Mirrored reports whether the stored pixels must be mirrored horizontally
+// before being rotated.
+func (o Orientation) Mirrored() bool {
+	switch o {
+	case OrientationMirrorHorizontal, OrientationMirrorVertical,
+		OrientationMirrorHorizontalRotate270, OrientationMirrorHorizontalRotate90:
+		return true
+	}
+	return false
+}
+
+// SwapsDimensions reports whether the displayed image has the width and the
+// height of the stored pixels swapped.
+func (o Orientation) SwapsDimensions() bool {
+	return o >= OrientationMirrorHorizontalRotate270 && o <= OrientationRotate270
+}
+
+func (o Orientation) valid() bool {
+	return o >= OrientationNormal && o <= OrientationRotate270
+}
+
+// Orientation returns the orientation of the image, read from
+// Exif.Image.Orientation or, if it is missing, from Xmp.tiff.Orientation.
+// It returns OrientationNormal if both are missing or 0, which many cameras
+// write for an unknown orientation.
+func (i *Image) Orientation() (Orientation, error) {
+	if i.closed() {
+		return 0, ErrImageClosed
+	}
+
+	exifData := i.GetExifData()
+	defer exifData.Close()
+
+	datum, err := exifData.FindKey("Exif.Image.Orientation")
+	if err != nil {
+		return 0, err
+	}
+	if datum != nil {
+		v, err := datum.Int64(0)
+		if err != nil {
+			return 0, err
+		}
+		return readOrientation(v)
+	}
+
+	xmpData := i.GetXmpData()
+	defer xmpData.Close()
+
+	s, err := xmpString(xmpData, "Xmp.tiff.Orientation")
+	if err != nil || s == "" {
+		return OrientationNormal, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid orientation %q", s)
+	}
+
+	return readOrientation(int64(v))
+}
+
+// SetOrientation sets Exif.Image.Orientation and Xmp.tiff.Orientation.
+func (i *Image) SetOrientation(o Orientation) error {
+	return i.edit(func(e *Editor) error {
+		return e.SetOrientation(o)
+	})
+}
+
+// ResetOrientation sets the orientation to OrientationNormal, once the
+// pixels were transformed as described by the previous orientation. If the
+// transformation swapped the width and the height, so are the EXIF and XMP
+// pixel dimensions. It fails if the orientation is invalid, as it is then
+// unknown how the pixels were transformed.
+func (i *Image) ResetOrientation() error {
+	o, err := i.Orientation()
+	if err != nil {
+		return err
+	}
+
+	exifData := i.GetExifData()
+	defer exifData.Close()
+	xmpData := i.GetXmpData()
+	defer xmpData.Close()
+
+	return i.edit(func(e *Editor) error {
+		if err := e.SetOrientation(OrientationNormal); err != nil {
+			return err
+		}
+		if !o.SwapsDimensions() {
+			return nil
+		}
+
+		if err := swapExifValues(e, exifData, "Exif.Photo.PixelXDimension", "Exif.Photo.PixelYDimension"); err != nil {
+			return err
+		}
+		if err := swapXmpValues(e, xmpData, "Xmp.exif.PixelXDimension", "Xmp.exif.PixelYDimension"); err != nil {
+			return err
+		}
+		return swapXmpValues(e, xmpData, "Xmp.tiff.ImageWidth", "Xmp.tiff.ImageLength")
+	})
+}
+
+// SetOrientation sets Exif.Image.Orientation and Xmp.tiff.Orientation.
+func (e *Editor) SetOrientation(o Orientation) error {
+	if _, err := checkOrientation(o); err != nil {
+		return err
+	}
+
+	if err := e.SetExifValue("Exif.Image.Orientation", NewUnsignedShortValue(uint16(o))); err != nil {
+		return err
+	}
+	return e.SetXmpString("Xmp.tiff.Orientation", strconv.Itoa(int(o)))
+}
+
+// readOrientation returns the orientation of a tag value, where 0 means
+// unknown.
+func readOrientation(v int64) (Orientation, error) {
+	if v == 0 {
+		return OrientationNormal, nil
+	}
+	return checkOrientation(Orientation(v))
+}
+
+func checkOrientation(o Orientation) (Orientation, error) {
+	if !o.valid() {
+		return 0, fmt.Errorf("invalid orientation %d", int(o))
+	}
+	return o, nil
+}
+
+// swapExifValues swaps the values of two EXIF tags, keeping their types. A
+// missing tag is swapped too, i.e. the other one is removed.
+func swapExifValues(e *Editor, d *ExifData, a, b string) error {
+	datumA, err := d.FindKey(a)
+	if err != nil {
+		return err
+	}
+	datumB, err := d.FindKey(b)
+	if err != nil {
+		return err
+	}
+
+	set := func(key string, datum *ExifDatum) error {
+		if datum == nil {
+			return e.ExifStripKey(key)
+		}
+		return e.SetExifValue(key, NewValue(datum.TypeID(), datum.String()))
+	}
+
+	if err := set(a, datumB); err != nil {
+		return err
+	}
+	return set(b, datumA)
+}
+
+// swapXmpValues swaps the values of two XMP properties. A missing property
+// is swapped too, i.e. the other one is removed.
+func swapXmpValues(e *Editor, d *XmpData, a, b string) error {
+	valueA, err := xmpString(d, a)
+	if err != nil {
+		return err
+	}
+	valueB, err := xmpString(d, b)
+	if err != nil {
+		return err
+	}
+
+	set := func(key, value string) error {
+		if value == "" {
+			return e.XmpStripKey(key)
+		}
+		return e.SetXmpString(key, value)
+	}
+
+	if err := set(a, valueB); err != nil {
+		return err
+	}
+	return set(b, valueA)
+}
@@ -0,0 +1,92 @@
+package goexiv
+
+// #cgo pkg-config: exiv2
+// #include "helper.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+// PreviewProperties describes a preview image embedded in an image, like
+// the EXIF thumbnail or the previews of a RAW file.
+type PreviewProperties struct {
+	MimeType  string
+	Extension string
+	Width     int
+	Height    int
+	Size      int64
+	// ID identifies the preview in calls to Preview.
+	ID int
+}
+
+// Previews returns the preview images embedded in the image, sorted by
+// increasing size. Read errors are dropped, it then returns nil as for an
+// image without previews. Use PreviewsErr to get them.
+func (i *Image) Previews() []PreviewProperties {
+	previews, _ := i.PreviewsErr()
+	return previews
+}
+
+// PreviewsErr is like Previews, but returns an error if the previews can't
+// be read, e.g. from a corrupt RAW file.
+func (i *Image) PreviewsErr() ([]PreviewProperties, error) {
+	if i.closed() {
+		return nil, ErrImageClosed
+	}
+	defer runtime.KeepAlive(i)
+
+	var n C.int
+	var cerr *C.Exiv2Error
+
+	cprops := C.exiv2_image_previews(i.img, &n, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return nil, err
+	}
+	defer C.exiv2_preview_properties_free(cprops, n)
+
+	if n == 0 {
+		return nil, nil
+	}
+
+	var previews []PreviewProperties
+	for _, p := range unsafe.Slice(cprops, int(n)) {
+		previews = append(previews, PreviewProperties{
+			MimeType:  C.GoString(p.mime_type),
+			Extension: C.GoString(p.extension),
+			Width:     int(p.width),
+			Height:    int(p.height),
+			Size:      int64(p.size),
+			ID:        int(p.id),
+		})
+	}
+
+	return previews, nil
+}
+
+// Preview returns the data of the preview image with the given ID.
+func (i *Image) Preview(id int) ([]byte, error) {
+	if i.closed() {
+		return nil, ErrImageClosed
+	}
+	defer runtime.KeepAlive(i)
+
+	var size C.long
+	var cerr *C.Exiv2Error
+
+	cData := C.exiv2_image_preview(i.img, C.int(id), &size, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return nil, err
+	}
+	defer C.free(unsafe.Pointer(cData))
+
+	return C.GoBytes(unsafe.Pointer(cData), C.int(size)), nil
+}
@@ -0,0 +1,119 @@
+package goexiv
+
+// #cgo pkg-config: exiv2
+// #include "helper.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// OpenReaderAt opens an image read from r and returns a pointer to the
+// corresponding Image object, but does not read the Metadata. For formats
+// like JPEG or PNG, Exiv2 reads only the parts of the image it needs, so
+// large files don't have to be loaded into memory. TIFF based formats,
+// including most RAW formats, are parsed from a memory mapping of the whole
+// file, so they are still read into memory entirely, as is any image once
+// GetBytes is called. r must not be modified while the image is open.
+// Start the parsing with a call to ReadMetadata()
+func OpenReaderAt(r io.ReaderAt, size int64) (*Image, error) {
+	if size <= 0 {
+		return nil, &Error{0, "input is empty"}
+	}
+
+	handle := cgo.NewHandle(r)
+
+	var cerr *C.Exiv2Error
+
+	cimg := C.exiv2_image_factory_open_reader(C.uintptr_t(handle), C.long(size), &cerr)
+
+	if cerr != nil {
+		handle.Delete()
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return nil, err
+	}
+
+	img := makeImage(cimg, nil)
+	img.reader = handle
+
+	return img, nil
+}
+
+// OpenReader opens an image read from r and returns a pointer to the
+// corresponding Image object, but does not read the Metadata. Readers with
+// random access and a known size are read from their start as needed, like
+// with OpenReaderAt. The size is given by a Size method, as for
+// *bytes.Reader or *io.SectionReader, a Stat method, as for *os.File, or by
+// seeking to the end. Other readers are read into memory.
+// Start the parsing with a call to ReadMetadata()
+func OpenReader(r io.Reader) (*Image, error) {
+	switch ra := r.(type) {
+	case interface {
+		io.ReaderAt
+		Size() int64
+	}:
+		return OpenReaderAt(ra, ra.Size())
+	case interface {
+		io.ReaderAt
+		Stat() (fs.FileInfo, error)
+	}:
+		info, err := ra.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return OpenReaderAt(ra, info.Size())
+	case interface {
+		io.ReaderAt
+		io.Seeker
+	}:
+		size, err := seekSize(ra)
+		if err != nil {
+			return nil, err
+		}
+		return OpenReaderAt(ra, size)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+}
+
+// seekSize returns the size of s by seeking to its end, then restores its
+// offset.
+func seekSize(s io.Seeker) (int64, error) {
+	offset, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	size, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+//export goexivReadAt
+func goexivReadAt(handle C.uintptr_t, buf *C.uchar, n C.long, off C.long) C.long {
+	r := cgo.Handle(handle).Value().(io.ReaderAt)
+
+	read, err := r.ReadAt(unsafe.Slice((*byte)(unsafe.Pointer(buf)), int(n)), int64(off))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return -1
+	}
+
+	return C.long(read)
+}
@@ -0,0 +1,205 @@
+package goexiv
+
+// #cgo pkg-config: exiv2
+// #include "helper.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// ShootingInfo summarizes the camera, the lens and the exposure settings an
+// image was taken with. Unknown fields are left empty or zero, and unknown
+// rationals have a zero Denominator.
+type ShootingInfo struct {
+	Make             string
+	Model            string
+	SerialNumber     string
+	LensModel        string
+	LensSerialNumber string
+	// FocalLength in millimeters.
+	FocalLength float64
+	// FNumber is the aperture, e.g. 2.8 for f/2.8.
+	FNumber float64
+	// ExposureTime in seconds, e.g. 1/250.
+	ExposureTime Rational
+	ISO          int
+	// Flash describes the flash mode and whether it fired, e.g. "Fired".
+	Flash string
+	// WhiteBalance is the white balance mode, e.g. "Auto".
+	WhiteBalance string
+	// ExposureBias in EV, e.g. -1/3.
+	ExposureBias Rational
+}
+
+// Exiv2 easy access functions, in the order of easyAccessFunctions in
+// helper.cpp.
+const (
+	easyMake = iota
+	easyModel
+	easySerialNumber
+	easyLensName
+	easyFocalLength
+	easyFNumber
+	easyExposureTime
+	easyISOSpeed
+	easyFlash
+	easyWhiteBalance
+	easyExposureBias
+)
+
+// ShootingInfo returns the camera, lens and exposure settings of the image.
+// Like the exiv2 command line tool, it falls back to the maker notes when
+// the standard EXIF tags are missing.
+func (i *Image) ShootingInfo() (*ShootingInfo, error) {
+	if i.closed() {
+		return nil, ErrImageClosed
+	}
+
+	d := i.GetExifData()
+	defer d.Close()
+
+	info := &ShootingInfo{}
+
+	text := []struct {
+		field int
+		dst   *string
+	}{
+		{easyMake, &info.Make},
+		{easyModel, &info.Model},
+		{easySerialNumber, &info.SerialNumber},
+		{easyLensName, &info.LensModel},
+		{easyFlash, &info.Flash},
+		{easyWhiteBalance, &info.WhiteBalance},
+	}
+	for _, f := range text {
+		datum, err := d.easyAccess(f.field)
+		if err != nil {
+			return nil, err
+		}
+		if datum != nil {
+			*f.dst = strings.TrimSpace(datum.print())
+		}
+	}
+
+	lensSerial, err := exifString(d, "Exif.Photo.LensSerialNumber")
+	if err != nil {
+		return nil, err
+	}
+	info.LensSerialNumber = strings.TrimSpace(lensSerial)
+
+	var focalLength, fNumber, iso Rational
+	numbers := []struct {
+		field int
+		dst   *Rational
+	}{
+		{easyFocalLength, &focalLength},
+		{easyFNumber, &fNumber},
+		{easyExposureTime, &info.ExposureTime},
+		{easyISOSpeed, &iso},
+		{easyExposureBias, &info.ExposureBias},
+	}
+	for _, f := range numbers {
+		datum, err := d.easyAccess(f.field)
+		if err != nil {
+			return nil, err
+		}
+		if datum != nil {
+			*f.dst, _ = easyRational(datum)
+		}
+	}
+
+	if focalLength.Denominator != 0 {
+		info.FocalLength = focalLength.Float64()
+	}
+	if fNumber.Denominator != 0 {
+		info.FNumber = fNumber.Float64()
+	}
+	if iso.Denominator != 0 {
+		info.ISO = int(math.Round(iso.Float64()))
+	}
+
+	return info, nil
+}
+
+// easyAccess returns the datum found by an Exiv2 easy access function, or
+// nil if there is none.
+func (d *ExifData) easyAccess(field int) (*ExifDatum, error) {
+	if d.closed() {
+		return nil, ErrImageClosed
+	}
+	defer runtime.KeepAlive(d)
+
+	var cerr *C.Exiv2Error
+
+	cdatum := C.exiv2_exif_data_easy_access(d.data, C.int(field), &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return nil, err
+	}
+
+	return makeExifDatum(d, cdatum), nil
+}
+
+// print returns the interpreted value of the datum, e.g. "F2.8" or the lens
+// name looked up from a maker note lens ID.
+func (d *ExifDatum) print() string {
+	if d.data.closed() {
+		return ""
+	}
+	defer runtime.KeepAlive(d)
+
+	cstr := C.exiv2_exif_datum_print(d.datum, d.data.data)
+	defer C.free(unsafe.Pointer(cstr))
+
+	return C.GoString(cstr)
+}
+
+// easyRational returns the value of a datum found by an easy access
+// function. Maker note tags often encode the value, so unless the datum is
+// a rational it is parsed from the interpreted value, e.g. "1/250 s",
+// "F2.8", "50.0 mm" or "+1/3 EV".
+func easyRational(d *ExifDatum) (Rational, bool) {
+	switch d.TypeID() {
+	case TypeUnsignedRational, TypeSignedRational:
+		r, err := d.Rational(0)
+		if err != nil || r.Denominator == 0 {
+			return Rational{}, false
+		}
+		return r, true
+	}
+
+	fields := strings.Fields(d.print())
+	if len(fields) == 0 {
+		return Rational{}, false
+	}
+	s := strings.TrimPrefix(strings.TrimPrefix(fields[0], "F"), "+")
+
+	if num, den, ok := strings.Cut(s, "/"); ok {
+		n, err := strconv.ParseInt(num, 10, 64)
+		if err != nil {
+			return Rational{}, false
+		}
+		m, err := strconv.ParseInt(den, 10, 64)
+		if err != nil || m == 0 {
+			return Rational{}, false
+		}
+		return Rational{n, m}, true
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return Rational{}, false
+	}
+	if v == math.Trunc(v) {
+		return Rational{int64(v), 1}, true
+	}
+	return Rational{int64(math.Round(v * 1000)), 1000}, true
+}
@@ -0,0 +1,104 @@
+package goexiv
+
+// #cgo pkg-config: exiv2
+// #include "helper.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+// OpenSidecar opens an XMP sidecar file and returns a pointer to the
+// corresponding Image object, but does not read the Metadata.
+// Start the parsing with a call to ReadMetadata()
+func OpenSidecar(path string) (*Image, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	var cerr *C.Exiv2Error
+
+	cimg := C.exiv2_image_factory_open_sidecar(cpath, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return nil, err
+	}
+
+	return makeImage(cimg, nil), nil
+}
+
+// NewSidecar returns an empty in-memory XMP sidecar. Use WriteSidecar to
+// save it to a file.
+func NewSidecar() (*Image, error) {
+	var cerr *C.Exiv2Error
+
+	cimg := C.exiv2_image_factory_new_sidecar(&cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return nil, err
+	}
+
+	return makeImage(cimg, nil), nil
+}
+
+// WriteSidecar writes the metadata of the image to an XMP sidecar file,
+// creating the file or replacing it if it exists. EXIF and IPTC data are
+// converted to XMP.
+func (i *Image) WriteSidecar(path string) error {
+	if i.closed() {
+		return ErrImageClosed
+	}
+	defer runtime.KeepAlive(i)
+
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_image_write_sidecar(i.img, cpath, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
+
+// MergeSidecar merges the XMP data of a sidecar file into the image. Values
+// of the sidecar replace the ones of the image.
+func (i *Image) MergeSidecar(path string) error {
+	return i.edit(func(e *Editor) error {
+		return e.MergeSidecar(path)
+	})
+}
+
+// MergeSidecar merges the XMP data of a sidecar file. Values of the sidecar
+// replace the ones of the image.
+func (e *Editor) MergeSidecar(path string) error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_editor_merge_xmp_sidecar(e.ed, cpath, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package goexiv
+
+// #cgo pkg-config: exiv2
+// #include "helper.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"unsafe"
+)
+
+// ExifThumbnail returns the MIME type and the data of the EXIF thumbnail,
+// or empty values if the image doesn't have one.
+func (i *Image) ExifThumbnail() (mime string, data []byte, err error) {
+	if i.closed() {
+		return "", nil, ErrImageClosed
+	}
+	defer runtime.KeepAlive(i)
+
+	var cMime *C.char
+	var size C.long
+	var cerr *C.Exiv2Error
+
+	cData := C.exiv2_image_exif_thumbnail(i.img, &cMime, &size, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return "", nil, err
+	}
+	if cData == nil {
+		return "", nil, nil
+	}
+
+	defer func() {
+		C.free(unsafe.Pointer(cMime))
+		C.free(unsafe.Pointer(cData))
+	}()
+
+	return C.GoString(cMime), C.GoBytes(unsafe.Pointer(cData), C.int(size)), nil
+}
+
+// SetExifThumbnail replaces the EXIF thumbnail with a JPEG image.
+func (i *Image) SetExifThumbnail(jpeg []byte) error {
+	return i.edit(func(e *Editor) error {
+		return e.SetExifThumbnail(jpeg)
+	})
+}
+
+// RemoveExifThumbnail removes the EXIF thumbnail and its tags, keeping the
+// rest of the EXIF data.
+func (i *Image) RemoveExifThumbnail() error {
+	return i.edit(func(e *Editor) error {
+		return e.RemoveExifThumbnail()
+	})
+}
+
+// SetExifThumbnail replaces the EXIF thumbnail with a JPEG image.
+func (e *Editor) SetExifThumbnail(jpeg []byte) error {
+	if !bytes.HasPrefix(jpeg, []byte{0xff, 0xd8}) {
+		return errors.New("thumbnail is not a JPEG image")
+	}
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_editor_set_exif_thumbnail(e.ed, (*C.uchar)(unsafe.Pointer(&jpeg[0])), C.long(len(jpeg)), &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
+
+// RemoveExifThumbnail removes the EXIF thumbnail and its tags, keeping the
+// rest of the EXIF data.
+func (e *Editor) RemoveExifThumbnail() error {
+	if err := e.check(); err != nil {
+		return err
+	}
+	defer runtime.KeepAlive(e)
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_editor_remove_exif_thumbnail(e.ed, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,430 @@
+package goexiv
+
+// #cgo pkg-config: exiv2
+// #include "helper.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// TypeID mirrors Exiv2::TypeId, the type of a metadata value.
+type TypeID int
+
+const (
+	TypeUnsignedByte     TypeID = 1
+	TypeAsciiString      TypeID = 2
+	TypeUnsignedShort    TypeID = 3
+	TypeUnsignedLong     TypeID = 4
+	TypeUnsignedRational TypeID = 5
+	TypeSignedByte       TypeID = 6
+	TypeUndefined        TypeID = 7
+	TypeSignedShort      TypeID = 8
+	TypeSignedLong       TypeID = 9
+	TypeSignedRational   TypeID = 10
+	TypeTiffFloat        TypeID = 11
+	TypeTiffDouble       TypeID = 12
+	TypeTiffIfd          TypeID = 13
+	TypeUnsignedLongLong TypeID = 16
+	TypeSignedLongLong   TypeID = 17
+	TypeTiffIfd8         TypeID = 18
+	TypeString           TypeID = 0x10000
+	TypeDate             TypeID = 0x10001
+	TypeTime             TypeID = 0x10002
+	TypeComment          TypeID = 0x10003
+	TypeDirectory        TypeID = 0x10004
+	TypeXmpText          TypeID = 0x10005
+	TypeXmpAlt           TypeID = 0x10006
+	TypeXmpBag           TypeID = 0x10007
+	TypeXmpSeq           TypeID = 0x10008
+	TypeLangAlt          TypeID = 0x10009
+	TypeInvalid          TypeID = 0x1fffe
+)
+
+// String returns the Exiv2 name of the type, e.g. "Rational".
+func (t TypeID) String() string {
+	name := C.exiv2_type_name(C.int(t))
+	if name == nil {
+		return fmt.Sprintf("TypeID(%d)", int(t))
+	}
+
+	return C.GoString(name)
+}
+
+// DefaultTypeID returns the type Exiv2's tag registry defines for a key of
+// the given metadata format, e.g. TypeUnsignedShort for
+// Exif.Image.Orientation.
+func DefaultTypeID(f MetadataFormat, key string) (TypeID, error) {
+	ckey := C.CString(key)
+	defer C.free(unsafe.Pointer(ckey))
+
+	var cerr *C.Exiv2Error
+	var t C.int
+
+	switch f {
+	case EXIF:
+		t = C.exiv2_exif_key_default_type_id(ckey, &cerr)
+	case IPTC:
+		t = C.exiv2_iptc_key_default_type_id(ckey, &cerr)
+	case XMP:
+		t = C.exiv2_xmp_key_default_type_id(ckey, &cerr)
+	default:
+		return TypeInvalid, errors.New("invalid metadata type")
+	}
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return TypeInvalid, err
+	}
+
+	return TypeID(t), nil
+}
+
+// isText reports whether values of the type hold a single piece of text
+// rather than a list of components.
+func (t TypeID) isText() bool {
+	switch t {
+	case TypeAsciiString, TypeString, TypeDate, TypeTime, TypeComment, TypeXmpText, TypeLangAlt:
+		return true
+	}
+	return false
+}
+
+// Rational is a fraction as stored in RATIONAL and SRATIONAL values.
+type Rational struct {
+	Numerator   int64
+	Denominator int64
+}
+
+// Float64 returns the value of the fraction. It returns an infinity or NaN
+// if the denominator is zero.
+func (r Rational) Float64() float64 {
+	return float64(r.Numerator) / float64(r.Denominator)
+}
+
+func (r Rational) String() string {
+	return fmt.Sprintf("%d/%d", r.Numerator, r.Denominator)
+}
+
+// Value is a typed metadata value, ready to be written with SetValue. Use
+// one of the New*Value functions to create it.
+type Value struct {
+	typeID TypeID
+	text   string
+	data   []byte
+	err    error
+}
+
+// TypeID returns the type of the value.
+func (v Value) TypeID() TypeID {
+	return v.typeID
+}
+
+// NewValue creates a value of type t from its Exiv2 text representation,
+// e.g. "1/250" for a rational or "1 2 3 0" for an undefined value.
+func NewValue(t TypeID, text string) Value {
+	return Value{typeID: t, text: text}
+}
+
+// NewAsciiValue creates an ASCII value, as used by most EXIF text tags.
+func NewAsciiValue(s string) Value {
+	return Value{typeID: TypeAsciiString, text: s}
+}
+
+// NewStringValue creates a string value, as used by IPTC text datasets.
+func NewStringValue(s string) Value {
+	return Value{typeID: TypeString, text: s}
+}
+
+// NewXmpTextValue creates a simple XMP text value.
+func NewXmpTextValue(s string) Value {
+	return Value{typeID: TypeXmpText, text: s}
+}
+
+// NewUnsignedByteValue creates a BYTE value.
+func NewUnsignedByteValue(b []byte) Value {
+	return Value{typeID: TypeUnsignedByte, data: b}
+}
+
+// NewUndefinedValue creates an UNDEFINED value holding an opaque blob of
+// bytes.
+func NewUndefinedValue(b []byte) Value {
+	return Value{typeID: TypeUndefined, data: b}
+}
+
+// NewUnsignedShortValue creates a SHORT value with one component per
+// argument.
+func NewUnsignedShortValue(v ...uint16) Value {
+	text := make([]string, len(v))
+	for i, n := range v {
+		text[i] = strconv.FormatUint(uint64(n), 10)
+	}
+
+	return Value{typeID: TypeUnsignedShort, text: strings.Join(text, " ")}
+}
+
+// NewSignedShortValue creates a SSHORT value with one component per
+// argument.
+func NewSignedShortValue(v ...int16) Value {
+	text := make([]string, len(v))
+	for i, n := range v {
+		text[i] = strconv.FormatInt(int64(n), 10)
+	}
+
+	return Value{typeID: TypeSignedShort, text: strings.Join(text, " ")}
+}
+
+// NewUnsignedLongValue creates a LONG value with one component per
+// argument.
+func NewUnsignedLongValue(v ...uint32) Value {
+	text := make([]string, len(v))
+	for i, n := range v {
+		text[i] = strconv.FormatUint(uint64(n), 10)
+	}
+
+	return Value{typeID: TypeUnsignedLong, text: strings.Join(text, " ")}
+}
+
+// NewSignedLongValue creates a SLONG value with one component per argument.
+func NewSignedLongValue(v ...int32) Value {
+	text := make([]string, len(v))
+	for i, n := range v {
+		text[i] = strconv.FormatInt(int64(n), 10)
+	}
+
+	return Value{typeID: TypeSignedLong, text: strings.Join(text, " ")}
+}
+
+// NewUnsignedRationalValue creates a RATIONAL value with one component per
+// argument. Numerators and denominators must fit in an uint32.
+func NewUnsignedRationalValue(v ...Rational) Value {
+	return newRationalValue(TypeUnsignedRational, 0, math.MaxUint32, v)
+}
+
+// NewSignedRationalValue creates a SRATIONAL value with one component per
+// argument. Numerators and denominators must fit in an int32.
+func NewSignedRationalValue(v ...Rational) Value {
+	return newRationalValue(TypeSignedRational, math.MinInt32, math.MaxInt32, v)
+}
+
+func newRationalValue(t TypeID, min, max int64, v []Rational) Value {
+	text := make([]string, len(v))
+	for i, r := range v {
+		if r.Numerator < min || r.Numerator > max || r.Denominator < min || r.Denominator > max {
+			return Value{typeID: t, err: fmt.Errorf("rational %s out of range for type %s", r, t)}
+		}
+		text[i] = r.String()
+	}
+
+	return Value{typeID: t, text: strings.Join(text, " ")}
+}
+
+// Charset is the character set of a comment value.
+type Charset string
+
+const (
+	CharsetASCII     Charset = "Ascii"
+	CharsetJIS       Charset = "Jis"
+	CharsetUnicode   Charset = "Unicode"
+	CharsetUndefined Charset = "Undefined"
+)
+
+// NewCommentValue creates a comment value, as used by
+// Exif.Photo.UserComment, with the given character set.
+func NewCommentValue(charset Charset, text string) Value {
+	return Value{typeID: TypeComment, text: "charset=" + string(charset) + " " + text}
+}
+
+// NewDateValue creates an IPTC date value from the date part of t.
+func NewDateValue(t time.Time) Value {
+	return Value{typeID: TypeDate, text: t.Format("2006-01-02")}
+}
+
+// NewTimeValue creates an IPTC time value from the time and zone offset of
+// t.
+func NewTimeValue(t time.Time) Value {
+	return Value{typeID: TypeTime, text: t.Format("15:04:05-07:00")}
+}
+
+// datumValue is a read-only view on the value of a datum. It stays valid as
+// long as the image it belongs to is open.
+type datumValue struct {
+	img *Image
+	val *C.Exiv2Value
+	// owner is the datum the value belongs to, kept alive with the view.
+	owner interface{}
+}
+
+func (v *datumValue) typeID() TypeID {
+	if v.img.closed() {
+		return TypeInvalid
+	}
+	defer runtime.KeepAlive(v)
+
+	return TypeID(C.exiv2_value_type_id(v.val))
+}
+
+func (v *datumValue) count() int {
+	if v.img.closed() {
+		return 0
+	}
+	defer runtime.KeepAlive(v)
+
+	return int(C.exiv2_value_count(v.val))
+}
+
+func (v *datumValue) size() int {
+	if v.img.closed() {
+		return 0
+	}
+	defer runtime.KeepAlive(v)
+
+	return int(C.exiv2_value_size(v.val))
+}
+
+func (v *datumValue) int64(n int) (int64, error) {
+	if v.img.closed() {
+		return 0, ErrImageClosed
+	}
+	defer runtime.KeepAlive(v)
+
+	var cerr *C.Exiv2Error
+
+	l := C.exiv2_value_to_long(v.val, C.long(n), &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return 0, err
+	}
+
+	return int64(l), nil
+}
+
+func (v *datumValue) float64(n int) (float64, error) {
+	if v.img.closed() {
+		return 0, ErrImageClosed
+	}
+	defer runtime.KeepAlive(v)
+
+	var cerr *C.Exiv2Error
+
+	f := C.exiv2_value_to_double(v.val, C.long(n), &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return 0, err
+	}
+
+	return float64(f), nil
+}
+
+func (v *datumValue) rational(n int) (Rational, error) {
+	if v.img.closed() {
+		return Rational{}, ErrImageClosed
+	}
+	defer runtime.KeepAlive(v)
+
+	var cerr *C.Exiv2Error
+	var num, den C.long
+
+	C.exiv2_value_to_rational(v.val, C.long(n), &num, &den, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return Rational{}, err
+	}
+
+	return Rational{int64(num), int64(den)}, nil
+}
+
+func (v *datumValue) bytes() []byte {
+	if v.img.closed() {
+		return nil
+	}
+	defer runtime.KeepAlive(v)
+
+	size := C.exiv2_value_size(v.val)
+	if size <= 0 {
+		return nil
+	}
+
+	buf := make([]byte, size)
+	n := C.exiv2_value_copy(v.val, (*C.uchar)(unsafe.Pointer(&buf[0])))
+
+	return buf[:n]
+}
+
+func (v *datumValue) langAlt() (map[string]string, error) {
+	if v.img.closed() {
+		return nil, ErrImageClosed
+	}
+	defer runtime.KeepAlive(v)
+
+	if t := v.typeID(); t != TypeLangAlt {
+		return nil, fmt.Errorf("%s value is not a language alternative", t)
+	}
+
+	texts := map[string]string{}
+	for n := 0; n < v.count(); n++ {
+		var cerr *C.Exiv2Error
+		var cLang, cText *C.char
+
+		C.exiv2_value_lang_alt_at(v.val, C.long(n), &cLang, &cText, &cerr)
+
+		if cerr != nil {
+			err := makeError(cerr)
+			C.exiv2_error_free(cerr)
+			return nil, err
+		}
+
+		texts[C.GoString(cLang)] = C.GoString(cText)
+		C.free(unsafe.Pointer(cLang))
+		C.free(unsafe.Pointer(cText))
+	}
+
+	return texts, nil
+}
+
+func (v *datumValue) values() ([]string, error) {
+	if v.img.closed() {
+		return nil, ErrImageClosed
+	}
+	defer runtime.KeepAlive(v)
+
+	if v.typeID().isText() {
+		cstr := C.exiv2_value_to_string(v.val)
+		defer C.free(unsafe.Pointer(cstr))
+
+		return []string{C.GoString(cstr)}, nil
+	}
+
+	values := make([]string, v.count())
+	for n := range values {
+		var cerr *C.Exiv2Error
+
+		cstr := C.exiv2_value_to_string_n(v.val, C.long(n), &cerr)
+
+		if cerr != nil {
+			err := makeError(cerr)
+			C.exiv2_error_free(cerr)
+			return nil, err
+		}
+
+		values[n] = C.GoString(cstr)
+		C.free(unsafe.Pointer(cstr))
+	}
+
+	return values, nil
+}
@@ -0,0 +1,101 @@
+package goexiv
+
+// #cgo pkg-config: exiv2
+// #include "helper.h"
+// #include <stdlib.h>
+import "C"
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"unsafe"
+)
+
+// writeChunkSize is the size of the chunks WriteTo copies the image in.
+const writeChunkSize = 64 * 1024
+
+// WriteTo writes the image contents, including metadata changes, to w. The
+// contents are copied in chunks rather than all at once like GetBytes.
+func (i *Image) WriteTo(w io.Writer) (int64, error) {
+	if i.closed() {
+		return 0, ErrImageClosed
+	}
+	defer runtime.KeepAlive(i)
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_image_io_open(i.img, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return 0, err
+	}
+	defer C.exiv2_image_io_close(i.img)
+
+	cbuf := C.malloc(writeChunkSize)
+	defer C.free(cbuf)
+	buf := unsafe.Slice((*byte)(cbuf), writeChunkSize)
+
+	var written int64
+	for {
+		n := int(C.exiv2_image_io_read(i.img, (*C.uchar)(cbuf), writeChunkSize, &cerr))
+
+		if cerr != nil {
+			err := makeError(cerr)
+			C.exiv2_error_free(cerr)
+			return written, err
+		}
+		if n == 0 {
+			return written, nil
+		}
+
+		m, err := w.Write(buf[:n])
+		written += int64(m)
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+// SaveAs writes the image contents, including metadata changes, to a file.
+// The contents are written to a temporary file first, which then replaces
+// the file at path, so the file is never left half written.
+func (i *Image) SaveAs(path string) (err error) {
+	if i.closed() {
+		return ErrImageClosed
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = i.WriteTo(tmp); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
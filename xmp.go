@@ -6,7 +6,11 @@ package goexiv
 import "C"
 
 import (
+	"errors"
+	"fmt"
 	"runtime"
+	"strings"
+	"sync"
 	"unsafe"
 )
 
@@ -14,6 +18,9 @@ import (
 type XmpData struct {
 	img  *Image // We point to img to keep it alive
 	data *C.Exiv2XmpData
+	// ownsImg is true if img is the hidden image of ParseXmp, which Close
+	// closes too.
+	ownsImg bool
 }
 
 // XmpDatum stores the info of one xmp datum.
@@ -30,25 +37,48 @@ type XmpDatumIterator struct {
 
 func makeXmpData(img *Image, cdata *C.Exiv2XmpData) *XmpData {
 	data := &XmpData{
-		img,
-		cdata,
+		img:  img,
+		data: cdata,
 	}
 
 	runtime.SetFinalizer(data, func(x *XmpData) {
-		C.exiv2_xmp_data_free(x.data)
+		x.Close()
 	})
 
 	return data
 }
 
+// Close frees the underlying C++ structure. Calling Close more than once is
+// a no-op.
+func (d *XmpData) Close() error {
+	if d.data == nil {
+		return nil
+	}
+
+	C.exiv2_xmp_data_free(d.data)
+	d.data = nil
+	runtime.SetFinalizer(d, nil)
+
+	if d.ownsImg {
+		return d.img.Close()
+	}
+
+	return nil
+}
+
+// closed reports whether the data or the image it belongs to has been closed.
+func (d *XmpData) closed() bool {
+	return d.data == nil || d.img.closed()
+}
+
 func makeXmpDatum(data *XmpData, cdatum *C.Exiv2XmpDatum) *XmpDatum {
 	if cdatum == nil {
 		return nil
 	}
 
 	datum := &XmpDatum{
-		data,
-		cdatum,
+		data:  data,
+		datum: cdatum,
 	}
 
 	runtime.SetFinalizer(datum, func(x *XmpDatum) {
@@ -60,6 +90,11 @@ func makeXmpDatum(data *XmpData, cdatum *C.Exiv2XmpDatum) *XmpDatum {
 
 // GetXmpData returns the XmpData of an Image.
 func (i *Image) GetXmpData() *XmpData {
+	if i.closed() {
+		return &XmpData{img: i}
+	}
+	defer runtime.KeepAlive(i)
+
 	return makeXmpData(i, C.exiv2_image_get_xmp_data(i.img))
 }
 
@@ -67,6 +102,11 @@ func (i *Image) GetXmpData() *XmpData {
 // It returns an error if the key is invalid. If the key is not found, a
 // nil pointer will be returned
 func (d *XmpData) FindKey(key string) (*XmpDatum, error) {
+	if d.closed() {
+		return nil, ErrImageClosed
+	}
+	defer runtime.KeepAlive(d)
+
 	ckey := C.CString(key)
 	defer C.free(unsafe.Pointer(ckey))
 
@@ -80,17 +120,76 @@ func (d *XmpData) FindKey(key string) (*XmpDatum, error) {
 		return nil, err
 	}
 
-	runtime.KeepAlive(d)
 	return makeXmpDatum(d, cdatum), nil
 }
 
 func (d *XmpDatum) String() string {
+	if d.data.img.closed() {
+		return ""
+	}
+	defer runtime.KeepAlive(d)
+
 	cstr := C.exiv2_xmp_datum_to_string(d.datum)
 	defer C.free(unsafe.Pointer(cstr))
 
 	return C.GoString(cstr)
 }
 
+// value returns the value of the datum.
+func (d *XmpDatum) value() *datumValue {
+	if d.data.img.closed() {
+		return &datumValue{img: d.data.img}
+	}
+
+	return &datumValue{
+		img:   d.data.img,
+		val:   C.exiv2_xmp_datum_value(d.datum),
+		owner: d,
+	}
+}
+
+// TypeID returns the type of the datum value.
+func (d *XmpDatum) TypeID() TypeID {
+	return d.value().typeID()
+}
+
+// Count returns the number of components of the datum value.
+func (d *XmpDatum) Count() int {
+	return d.value().count()
+}
+
+// Size returns the size of the datum value in bytes.
+func (d *XmpDatum) Size() int {
+	return d.value().size()
+}
+
+// Int64 returns the n-th component of the datum value as an integer.
+func (d *XmpDatum) Int64(n int) (int64, error) {
+	return d.value().int64(n)
+}
+
+// Float64 returns the n-th component of the datum value as a float.
+func (d *XmpDatum) Float64(n int) (float64, error) {
+	return d.value().float64(n)
+}
+
+// Rational returns the n-th component of the datum value as a rational.
+func (d *XmpDatum) Rational(n int) (Rational, error) {
+	return d.value().rational(n)
+}
+
+// Bytes returns the raw data of the datum value. Multi-byte numbers are
+// encoded in little-endian byte order.
+func (d *XmpDatum) Bytes() []byte {
+	return d.value().bytes()
+}
+
+// Values returns every component of the datum value as a string. Text values
+// are returned as a single element.
+func (d *XmpDatum) Values() ([]string, error) {
+	return d.value().values()
+}
+
 func (i *Image) XmpStripKey(key string) error {
 	return i.StripKey(XMP, key)
 }
@@ -99,6 +198,312 @@ func (i *Image) SetXmpString(key, value string) error {
 	return i.SetMetadataString(XMP, key, value)
 }
 
+// SetXmpValue sets an XMP key with a given typed value.
+func (i *Image) SetXmpValue(key string, v Value) error {
+	return i.SetValue(XMP, key, v)
+}
+
+// customXmpNamespaces keeps the prefixes of the namespaces registered with
+// RegisterXmpNamespace. Exiv2 only reports namespaces with an http:// URI in
+// its list of registered namespaces, the others are looked up by prefix.
+var customXmpNamespaces = struct {
+	sync.Mutex
+	prefixes map[string]bool
+}{prefixes: map[string]bool{}}
+
+// RegisterXmpNamespace registers a custom XMP namespace, so that keys like
+// "Xmp.<prefix>.<property>" can be read and written. Registrations are global
+// to the process.
+func RegisterXmpNamespace(uri, prefix string) error {
+	if uri == "" || prefix == "" {
+		return errors.New("xmp namespace uri and prefix must not be empty")
+	}
+
+	cURI := C.CString(uri)
+	cPrefix := C.CString(prefix)
+
+	defer func() {
+		C.free(unsafe.Pointer(cURI))
+		C.free(unsafe.Pointer(cPrefix))
+	}()
+
+	customXmpNamespaces.Lock()
+	defer customXmpNamespaces.Unlock()
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_xmp_register_ns(cURI, cPrefix, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	customXmpNamespaces.prefixes[prefix] = true
+
+	return nil
+}
+
+// UnregisterXmpNamespace removes a custom XMP namespace registered with
+// RegisterXmpNamespace. Namespaces built into Exiv2 can't be removed.
+func UnregisterXmpNamespace(prefix string) error {
+	customXmpNamespaces.Lock()
+	defer customXmpNamespaces.Unlock()
+
+	if !customXmpNamespaces.prefixes[prefix] {
+		return fmt.Errorf("%q is not a custom xmp namespace prefix", prefix)
+	}
+
+	cPrefix := C.CString(prefix)
+	defer C.free(unsafe.Pointer(cPrefix))
+
+	var cerr *C.Exiv2Error
+
+	C.exiv2_xmp_unregister_ns(cPrefix, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return err
+	}
+
+	delete(customXmpNamespaces.prefixes, prefix)
+
+	return nil
+}
+
+// XmpNamespaces returns the URIs of all known XMP namespaces, built-in and
+// custom, indexed by prefix. The URIs are the ones registered in Exiv2,
+// which appends a trailing '/' to the custom ones if needed.
+func XmpNamespaces() map[string]string {
+	var cPrefixes, cURIs **C.char
+
+	n := int(C.exiv2_xmp_namespaces(&cPrefixes, &cURIs))
+	defer func() {
+		C.free(unsafe.Pointer(cPrefixes))
+		C.free(unsafe.Pointer(cURIs))
+	}()
+
+	namespaces := make(map[string]string, n)
+	if n > 0 {
+		prefixes := unsafe.Slice(cPrefixes, n)
+		uris := unsafe.Slice(cURIs, n)
+		for i := 0; i < n; i++ {
+			namespaces[C.GoString(prefixes[i])] = C.GoString(uris[i])
+			C.free(unsafe.Pointer(prefixes[i]))
+			C.free(unsafe.Pointer(uris[i]))
+		}
+	}
+
+	customXmpNamespaces.Lock()
+	defer customXmpNamespaces.Unlock()
+
+	for prefix := range customXmpNamespaces.prefixes {
+		if _, ok := namespaces[prefix]; ok {
+			continue
+		}
+
+		cPrefix := C.CString(prefix)
+		cURI := C.exiv2_xmp_ns(cPrefix)
+		if cURI != nil {
+			namespaces[prefix] = C.GoString(cURI)
+			C.free(unsafe.Pointer(cURI))
+		}
+		C.free(unsafe.Pointer(cPrefix))
+	}
+
+	return namespaces
+}
+
+// XmpFormatFlags control how XMP data is serialized. They mirror Exiv2's
+// XmpParser::XmpFormatFlags.
+type XmpFormatFlags uint32
+
+const (
+	XmpOmitPacketWrapper   XmpFormatFlags = 0x0010
+	XmpReadOnlyPacket      XmpFormatFlags = 0x0020
+	XmpUseCompactFormat    XmpFormatFlags = 0x0040
+	XmpIncludeThumbnailPad XmpFormatFlags = 0x0100
+	XmpExactPacketLength   XmpFormatFlags = 0x0200
+	XmpWriteAliasComments  XmpFormatFlags = 0x0400
+	XmpOmitAllFormatting   XmpFormatFlags = 0x0800
+)
+
+// XmpSerializeOptions are the options of SerializeXmp. Padding is the number
+// of bytes of whitespace added at the end of the packet, or its total length
+// with XmpExactPacketLength.
+type XmpSerializeOptions struct {
+	Flags   XmpFormatFlags
+	Padding uint32
+}
+
+// XmpPacket returns the serialized XMP packet of the image as it was last
+// read or written.
+func (i *Image) XmpPacket() ([]byte, error) {
+	if i.closed() {
+		return nil, ErrImageClosed
+	}
+	defer runtime.KeepAlive(i)
+
+	var size C.long
+
+	buf := C.exiv2_image_xmp_packet(i.img, &size)
+	defer C.free(unsafe.Pointer(buf))
+
+	return C.GoBytes(unsafe.Pointer(buf), C.int(size)), nil
+}
+
+// SetXmpPacket replaces the XMP data of the image with a serialized XMP
+// packet. The packet is written as is.
+func (i *Image) SetXmpPacket(packet []byte) error {
+	return i.edit(func(e *Editor) error {
+		return e.SetXmpPacket(packet)
+	})
+}
+
+// SerializeXmp serializes XMP data to an XMP packet.
+func SerializeXmp(d *XmpData, opts XmpSerializeOptions) ([]byte, error) {
+	if d.closed() {
+		return nil, ErrImageClosed
+	}
+	defer runtime.KeepAlive(d)
+
+	var size C.long
+	var cerr *C.Exiv2Error
+
+	buf := C.exiv2_xmp_data_serialize(d.data, C.uint(opts.Flags), C.uint(opts.Padding), &size, &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return nil, err
+	}
+	defer C.free(unsafe.Pointer(buf))
+
+	return C.GoBytes(unsafe.Pointer(buf), C.int(size)), nil
+}
+
+// ParseXmp parses a serialized XMP packet. The returned data is not bound to
+// an image, its Close frees all the memory used by the parsed packet.
+func ParseXmp(packet []byte) (*XmpData, error) {
+	var cPacket *C.char
+	if len(packet) > 0 {
+		cPacket = (*C.char)(unsafe.Pointer(&packet[0]))
+	}
+
+	var cerr *C.Exiv2Error
+
+	cimg := C.exiv2_xmp_parse(cPacket, C.long(len(packet)), &cerr)
+
+	if cerr != nil {
+		err := makeError(cerr)
+		C.exiv2_error_free(cerr)
+		return nil, err
+	}
+
+	img := makeImage(cimg, nil)
+	data := img.GetXmpData()
+	data.ownsImg = true
+
+	return data, nil
+}
+
+// XmpStructField returns the key of a field of an XMP structure, e.g.
+// XmpStructField("Xmp.iptc.CreatorContactInfo", "Iptc4xmpCore:CiEmailWork").
+func XmpStructField(structKey, field string) string {
+	return structKey + "/" + field
+}
+
+// XmpArrayItem returns the key of an item of an XMP array. Indexes start at
+// 1, as in XMP paths.
+func XmpArrayItem(arrayKey string, index int) string {
+	return fmt.Sprintf("%s[%d]", arrayKey, index)
+}
+
+// SetXmpArray sets an XMP key to an array of the given kind, which must be
+// TypeXmpBag, TypeXmpSeq or TypeXmpAlt.
+func (i *Image) SetXmpArray(key string, kind TypeID, values []string) error {
+	return i.edit(func(e *Editor) error {
+		return e.SetXmpArray(key, kind, values)
+	})
+}
+
+// SetLangAlt sets the text of one language of an XMP language alternative,
+// keeping the other languages. Use "x-default" for the default language.
+func (i *Image) SetLangAlt(key, lang, value string) error {
+	return i.edit(func(e *Editor) error {
+		return e.SetLangAlt(key, lang, value)
+	})
+}
+
+// SetXmpStruct sets the fields of an XMP structure, creating the structure
+// if needed. Field names are qualified with their namespace prefix, e.g.
+// "Iptc4xmpCore:CiEmailWork".
+func (i *Image) SetXmpStruct(key string, fields map[string]string) error {
+	return i.edit(func(e *Editor) error {
+		return e.SetXmpStruct(key, fields)
+	})
+}
+
+// GetArray returns the items of an XMP array. A simple XMP value is returned
+// as a single item.
+func (d *XmpData) GetArray(key string) ([]string, error) {
+	datum, err := d.FindKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if datum == nil {
+		return nil, ErrMetadataKeyNotFound
+	}
+
+	return datum.Values()
+}
+
+// GetLangAlt returns the texts of an XMP language alternative, indexed by
+// language.
+func (d *XmpData) GetLangAlt(key string) (map[string]string, error) {
+	datum, err := d.FindKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if datum == nil {
+		return nil, ErrMetadataKeyNotFound
+	}
+
+	return datum.value().langAlt()
+}
+
+// GetStruct returns the fields of an XMP structure, indexed by their path
+// relative to the structure, e.g. "Iptc4xmpCore:CiEmailWork". Fields of
+// nested structures and arrays are returned with their full relative path.
+func (d *XmpData) GetStruct(key string) (map[string]string, error) {
+	if d.closed() {
+		return nil, ErrImageClosed
+	}
+
+	prefix := key + "/"
+	fields := map[string]string{}
+
+	iter := d.Iterator()
+	defer iter.Close()
+
+	for iter.HasNext() {
+		datum := iter.Next()
+		if k := datum.Key(); strings.HasPrefix(k, prefix) {
+			fields[strings.TrimPrefix(k, prefix)] = datum.String()
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, ErrMetadataKeyNotFound
+	}
+
+	return fields, nil
+}
+
 func (d *XmpData) GetString(key string) (string, error) {
 	datum, err := d.FindKey(key)
 	if err != nil {
@@ -115,19 +520,85 @@ func (d *XmpData) GetString(key string) (string, error) {
 // AllTags returns all ZMP tags
 func (d *XmpData) AllTags() map[string]string {
 	keyValues := map[string]string{}
-	for i := d.Iterator(); i.HasNext(); {
-		d := i.Next()
+	iter := d.Iterator()
+	defer iter.Close()
+
+	for iter.HasNext() {
+		d := iter.Next()
 		keyValues[d.Key()] = d.String()
 	}
 
 	return keyValues
 }
 
+// AllEntries returns all XMP entries in the order they are stored, keeping
+// every entry of keys that occur more than once.
+func (d *XmpData) AllEntries() []Entry {
+	var entries []Entry
+	indexes := map[string]int{}
+
+	iter := d.Iterator()
+	defer iter.Close()
+
+	for iter.HasNext() {
+		datum := iter.Next()
+		key := datum.Key()
+
+		entries = append(entries, Entry{
+			Key:    key,
+			Value:  datum.String(),
+			TypeID: datum.TypeID(),
+			Index:  indexes[key],
+		})
+		indexes[key]++
+	}
+
+	return entries
+}
+
+// GetAll returns the values of every entry with the given key, in the order
+// they are stored.
+func (d *XmpData) GetAll(key string) ([]string, error) {
+	datum, err := d.FindKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if datum == nil {
+		return nil, ErrMetadataKeyNotFound
+	}
+
+	// FindKey has validated the key, now use its canonical form
+	key = datum.Key()
+
+	var values []string
+
+	iter := d.Iterator()
+	defer iter.Close()
+
+	for iter.HasNext() {
+		datum := iter.Next()
+		if datum.Key() == key {
+			values = append(values, datum.String())
+		}
+	}
+
+	return values, nil
+}
+
 // XmpStripMetadata removes all EXIF metadata except the keys in the unless array.
 func (i *Image) XmpStripMetadata(unless []string) error {
+	if i.closed() {
+		return ErrImageClosed
+	}
+	defer runtime.KeepAlive(i)
+
 	var cErr *C.Exiv2Error
 
-	tagsToRemove := getKeysToRemove(i.GetXmpData(), unless)
+	data := i.GetXmpData()
+	defer data.Close()
+
+	tagsToRemove := getKeysToRemove(data, unless)
 	if len(tagsToRemove) == 0 {
 		return nil
 	}
@@ -152,16 +623,31 @@ func (i *Image) XmpStripMetadata(unless []string) error {
 
 // Iterator returns a new XmpDatumIterator to iterate over all IPTC data.
 func (d *XmpData) Iterator() *XmpDatumIterator {
+	if d.closed() {
+		return &XmpDatumIterator{data: d}
+	}
+	defer runtime.KeepAlive(d)
+
 	return makeXmpDatumIterator(d, C.exiv2_xmp_data_iterator(d.data))
 }
 
 // HasNext returns true as long as the iterator has another datum to deliver.
 func (i *XmpDatumIterator) HasNext() bool {
+	if i.closed() {
+		return false
+	}
+	defer runtime.KeepAlive(i)
+
 	return C.exiv2_xmp_data_iterator_has_next(i.iter) != 0
 }
 
 // Next returns the next XmpDatum of the iterator or nil if iterator has reached the end.
 func (i *XmpDatumIterator) Next() *XmpDatum {
+	if i.closed() {
+		return nil
+	}
+	defer runtime.KeepAlive(i)
+
 	return makeXmpDatum(i.data, C.exiv2_xmp_datum_iterator_next(i.iter))
 }
 
@@ -169,13 +655,41 @@ func makeXmpDatumIterator(data *XmpData, cIter *C.Exiv2XmpDatumIterator) *XmpDat
 	datum := &XmpDatumIterator{data, cIter}
 
 	runtime.SetFinalizer(datum, func(i *XmpDatumIterator) {
-		C.exiv2_xmp_datum_iterator_free(i.iter)
+		i.Close()
 	})
 
 	return datum
 }
 
+// Close frees the underlying C++ iterator. Calling Close more than once is a
+// no-op.
+func (i *XmpDatumIterator) Close() error {
+	if i.iter == nil {
+		return nil
+	}
+
+	C.exiv2_xmp_datum_iterator_free(i.iter)
+	i.iter = nil
+	runtime.SetFinalizer(i, nil)
+
+	return nil
+}
+
+// closed reports whether the iterator or the image it belongs to has been
+// closed.
+func (i *XmpDatumIterator) closed() bool {
+	return i.iter == nil || i.data.img.closed()
+}
+
 // Key returns the XMP key of the datum.
 func (d *XmpDatum) Key() string {
-	return C.GoString(C.exiv2_xmp_datum_key(d.datum))
+	if d.data.img.closed() {
+		return ""
+	}
+	defer runtime.KeepAlive(d)
+
+	cstr := C.exiv2_xmp_datum_key(d.datum)
+	defer C.free(unsafe.Pointer(cstr))
+
+	return C.GoString(cstr)
 }